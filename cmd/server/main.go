@@ -1,24 +1,35 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"oktalk/internal/pkg/config"
 	"oktalk/internal/pkg/log"
 	"oktalk/internal/pkg/trace"
 	"oktalk/internal/router"
 	"oktalk/internal/servicecontext"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	configPath := flag.String("config", "", "配置文件路径，默认读取环境变量 OKTALK_CONFIG 或 "+config.DefaultConfigFile)
+	flag.Parse()
+
 	// 1. 初始化配置
-	conf := config.InitConfig()
+	conf := config.InitConfig(*configPath)
 	// 2. 日志配置
 	log.InitLog(conf)
 	// 3. trace配置
-	shutdown := trace.InitOpenTelemetry()
-	defer shutdown()
+	shutdownTracer := trace.InitOpenTelemetry(&conf.Trace)
 	// 4.
 	svcctx := servicecontext.NewServiceContext(conf)
 
@@ -26,8 +37,66 @@ func main() {
 	r := router.InitRouter(svcctx)
 
 	// 6. 启动服务
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", conf.Server.Port),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       seconds(conf.Server.ReadTimeoutSeconds),
+		WriteTimeout:      seconds(conf.Server.WriteTimeoutSeconds),
+		IdleTimeout:       seconds(conf.Server.IdleTimeoutSeconds),
+	}
+	// 先绑定端口再标记就绪，端口被占用时直接退出，不会在就绪探针通过后才失败
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		logrus.Fatalf("❌ Server 启动失败: %v", err)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+	svcctx.SetReady(true)
 	logrus.Infof("🔥 OKTalk Server 启动成功! 监听端口: %d", conf.Server.Port)
-	if err := r.Run(fmt.Sprintf(":%d", conf.Server.Port)); err != nil {
+
+	// 7. 等待退出信号
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
 		logrus.Fatalf("❌ Server 启动失败: %v", err)
+	case sig := <-quit:
+		logrus.Infof("收到信号 %s，开始优雅退出", sig)
 	}
+	// 再次收到信号时立即退出
+	signal.Stop(quit)
+
+	// 8. 先标记为未就绪，等负载均衡摘除流量
+	svcctx.SetReady(false)
+	time.Sleep(seconds(conf.Server.DrainDelaySeconds))
+
+	// 9. 停止接收新连接，等待进行中的请求（包括 SSE 流式回复）结束
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(conf.Server.ShutdownTimeoutSeconds))
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logrus.Warnf("⚠️ 等待请求结束超时，强制断开剩余连接: %v", err)
+		srv.Close()
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logrus.Errorf("❌ Server 异常退出: %v", err)
+	}
+
+	// 10. 等待保存对话记录等后台任务，释放资源，最后导出剩余的 trace
+	// 等待后台任务单独计时，请求结束得晚也不会挤占这段时间
+	taskCtx, taskCancel := context.WithTimeout(context.Background(), seconds(conf.Server.TaskTimeoutSeconds))
+	defer taskCancel()
+	svcctx.Close(taskCtx)
+	traceCtx, traceCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer traceCancel()
+	if err := shutdownTracer(traceCtx); err != nil {
+		logrus.Warnf("⚠️ 导出剩余 trace 失败: %v", err)
+	}
+	logrus.Info("👋 OKTalk Server 已退出")
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
 }
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"oktalk/internal/model"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/janitor"
+	"oktalk/internal/pkg/response"
+	"oktalk/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	usageService  *service.UsageService
+	parentService *service.ParentService
+	janitor       *janitor.Janitor
+}
+
+func NewAdminHandler(usageService *service.UsageService, parentService *service.ParentService, janitor *janitor.Janitor) *AdminHandler {
+	return &AdminHandler{
+		usageService:  usageService,
+		parentService: parentService,
+		janitor:       janitor,
+	}
+}
+
+// usageSummaryData 用量汇总接口的返回数据
+type usageSummaryData struct {
+	GroupBy  string                 `json:"group_by"`
+	From     string                 `json:"from"`
+	To       string                 `json:"to"`
+	Currency string                 `json:"currency"`
+	Items    []service.UsageSummary `json:"items"`
+	Total    service.UsageSummary   `json:"total"`
+}
+
+// UsageSummary 按天 / 用户 / 服务 / 模型汇总 AI 服务用量和成本
+// 参数：group_by（day / user / provider / model，默认 day）、from / to（YYYY-MM-DD，包含两端，默认最近 7 天）、user_id（可选）
+func (h *AdminHandler) UsageSummary(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	const layout = "2006-01-02"
+	today := time.Now().Format(layout)
+	from, err := time.ParseInLocation(layout, c.DefaultQuery("from", time.Now().AddDate(0, 0, -6).Format(layout)), time.Local)
+	if err != nil {
+		response.Error(c, errcode.Wrap(errcode.CodeParamError, err).WithDetail("from (YYYY-MM-DD)"))
+		return
+	}
+	to, err := time.ParseInLocation(layout, c.DefaultQuery("to", today), time.Local)
+	if err != nil || to.Before(from) {
+		response.Error(c, errcode.New(errcode.CodeParamError).WithDetail("to (YYYY-MM-DD, >= from)"))
+		return
+	}
+	var userID uint64
+	if raw := c.Query("user_id"); raw != "" {
+		if userID, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			response.Error(c, errcode.Wrap(errcode.CodeParamError, err).WithDetail("user_id"))
+			return
+		}
+	}
+
+	query := service.UsageQuery{
+		GroupBy: c.DefaultQuery("group_by", "day"),
+		From:    from,
+		To:      to.AddDate(0, 0, 1),
+		UserID:  uint(userID),
+	}
+	items, err := h.usageService.Summary(ctx, query)
+	if errors.Is(err, service.ErrUnsupportedGroupBy) {
+		response.Error(c, errcode.Wrap(errcode.CodeParamError, err).WithDetail("group_by (day / user / provider / model)"))
+		return
+	}
+	if err != nil {
+		response.Error(c, fmt.Errorf("查询用量汇总失败: %w", err))
+		return
+	}
+
+	data := usageSummaryData{
+		GroupBy:  query.GroupBy,
+		From:     from.Format(layout),
+		To:       to.Format(layout),
+		Currency: h.usageService.Currency(),
+		Items:    items,
+		Total:    service.UsageSummary{Key: "total"},
+	}
+	for _, item := range items {
+		data.Total.Requests += item.Requests
+		data.Total.PromptTokens += item.PromptTokens
+		data.Total.CompletionTokens += item.CompletionTokens
+		data.Total.AudioSeconds += item.AudioSeconds
+		data.Total.Characters += item.Characters
+		data.Total.Cost += item.Cost
+	}
+	response.SendJSON(c, errcode.CodeSuccess, data, "success")
+}
+
+// StorageCleanup 各类音频文件自启动以来回收的空间（文件数、字节数、最近一次后台清理时间）
+func (h *AdminHandler) StorageCleanup(c *gin.Context) {
+	response.SendJSON(c, errcode.CodeSuccess, h.janitor.Stats(), "success")
+}
+
+// createParentRequest 新建家长账号
+type createParentRequest struct {
+	Name     string `json:"name"`
+	Children []uint `json:"children"` // 绑定的孩子（X-User-ID）
+}
+
+// createParentData 家长令牌只在这里返回一次，之后无法再查到
+type createParentData struct {
+	Parent   *model.Parent `json:"parent"`
+	Token    string        `json:"token"`
+	Children []uint        `json:"children"`
+}
+
+// CreateParent 新建家长账号并绑定孩子
+func (h *AdminHandler) CreateParent(c *gin.Context) {
+	var req createParentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errcode.Wrap(errcode.CodeParamError, err))
+		return
+	}
+	if !validChildren(c, req.Children) {
+		return
+	}
+	parent, token, err := h.parentService.Create(c.Request.Context(), req.Name, req.Children)
+	if err != nil {
+		response.Error(c, fmt.Errorf("新建家长账号失败: %w", err))
+		return
+	}
+	response.SendJSON(c, errcode.CodeSuccess, createParentData{Parent: parent, Token: token, Children: req.Children}, "success")
+}
+
+// addChildrenRequest 给家长绑定孩子
+type addChildrenRequest struct {
+	Children []uint `json:"children"`
+}
+
+// AddChildren 给已有的家长绑定孩子，已经绑定的忽略
+func (h *AdminHandler) AddChildren(c *gin.Context) {
+	parentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errcode.New(errcode.CodeParamError).WithDetail("id"))
+		return
+	}
+	var req addChildrenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errcode.Wrap(errcode.CodeParamError, err))
+		return
+	}
+	if len(req.Children) == 0 {
+		response.Error(c, errcode.New(errcode.CodeParamError).WithDetail("children"))
+		return
+	}
+	if !validChildren(c, req.Children) {
+		return
+	}
+	err = h.parentService.AddChildren(c.Request.Context(), uint(parentID), req.Children)
+	if errors.Is(err, service.ErrParentNotFound) {
+		response.Error(c, errcode.Wrap(errcode.CodeParentNotFound, err))
+		return
+	}
+	if err != nil {
+		response.Error(c, fmt.Errorf("绑定孩子失败: %w", err))
+		return
+	}
+	response.SendJSON(c, errcode.CodeSuccess, nil, "success")
+}
+
+// validChildren 孩子的用户 ID 不能为 0，失败时已经写好响应
+func validChildren(c *gin.Context, children []uint) bool {
+	for _, userID := range children {
+		if userID == 0 {
+			response.Error(c, errcode.New(errcode.CodeParamError).WithDetail("children"))
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"oktalk/internal/pkg/blob"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BlobHandler 本地存储的下载接口，只接受 LocalStore.SignedURL 生成的签名链接
+// 使用 S3 存储时签名链接直接指向对象存储，不需要这个接口
+type BlobHandler struct {
+	store *blob.LocalStore
+}
+
+func NewBlobHandler(store *blob.LocalStore) *BlobHandler {
+	return &BlobHandler{
+		store: store,
+	}
+}
+
+// Download 校验签名后返回文件内容
+func (h *BlobHandler) Download(c *gin.Context) {
+	ctx := c.Request.Context()
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	if err := h.store.Verify(key, c.Query("expires"), c.Query("sig")); err != nil {
+		response.Error(c, errcode.Wrap(errcode.CodeLinkExpired, err))
+		return
+	}
+
+	reader, obj, err := h.store.Get(ctx, key)
+	if errors.Is(err, blob.ErrNotFound) {
+		response.Error(c, errcode.Wrap(errcode.CodeFileNotFound, err))
+		return
+	}
+	if err != nil {
+		response.Error(c, fmt.Errorf("读取文件失败: %w", err))
+		return
+	}
+	defer reader.Close()
+	c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, reader, nil)
+}
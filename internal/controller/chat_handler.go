@@ -1,13 +1,27 @@
 package controller
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"oktalk/internal/service"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"oktalk/internal/pkg/audio"
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/constants"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/metrics"
+	"oktalk/internal/pkg/response"
+	"oktalk/internal/pkg/tts"
+	"oktalk/internal/service"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -15,11 +29,15 @@ import (
 
 type ChatHandler struct {
 	chatService *service.ChatService
+	tempDir     string // 上传音频在识别期间的临时目录
+	upload      config.UploadConfig
 }
 
-func NewChatHandler(chatService *service.ChatService) *ChatHandler {
+func NewChatHandler(chatService *service.ChatService, tempDir string, upload config.UploadConfig) *ChatHandler {
 	return &ChatHandler{
 		chatService: chatService,
+		tempDir:     tempDir,
+		upload:      upload,
 	}
 }
 
@@ -27,38 +45,215 @@ func NewChatHandler(chatService *service.ChatService) *ChatHandler {
 func (h *ChatHandler) VoiceChat(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	// 2. 获取上传的文件
-	file, err := c.FormFile("audio")
+	upload, ok := h.receiveAudio(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.chatService.ProcessVoiceChat(ctx, &service.VoiceChatRequest{
+		UserID:     c.GetUint(constants.UserIDKey),
+		ClientIP:   c.ClientIP(),
+		SessionID:  parseSessionID(c.PostForm("session_id")),
+		AudioPath:  upload.path,
+		AudioData:  upload.data,
+		Voice:      c.PostForm("voice"),
+		FocusWords: parseFocusWords(c.PostForm("focus_words")),
+		Subtitle:   c.PostForm("subtitle"),
+	})
 	if err != nil {
-		logrus.WithContext(ctx).Errorf("❌ 获取上传文件失败: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "未检测到音频文件上传"})
+		response.Error(c, err)
 		return
 	}
+	if result.Blocked != "" {
+		response.Error(c, errcode.New(errcode.CodeParentalLimit).WithData(result))
+		return
+	}
+
+	response.SendJSON(c, errcode.CodeSuccess, result, "success")
+}
 
-	// 3. 确保临时目录存在
-	tempDir := "storage/temp/audio"
-	if _, err := os.Stat(tempDir); os.IsNotExist(err) {
-		_ = os.MkdirAll(tempDir, os.ModePerm)
+// VoiceChatStream 处理语音上传与 AI 对话，回复语音通过 SSE 边合成边推送
+// 受家长限制时 transcript 中 blocked 不为空，audio 为提示语音
+// 事件顺序：transcript（识别文本和回复文本）-> audio（base64 音频分片，若干个）-> subtitles（请求了字幕时）-> done 或 error
+func (h *ChatHandler) VoiceChatStream(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	upload, ok := h.receiveAudio(c)
+	if !ok {
+		return
 	}
 
-	// 4. 构建唯一文件名 (时间戳 + UUID)
-	filename := fmt.Sprintf("%d_%s%s", time.Now().Unix(), uuid.New().String()[:8], filepath.Ext(file.Filename))
-	savePath := filepath.Join(tempDir, filename)
+	result, stream, err := h.chatService.StreamVoiceChat(ctx, &service.VoiceChatRequest{
+		UserID:     c.GetUint(constants.UserIDKey),
+		ClientIP:   c.ClientIP(),
+		SessionID:  parseSessionID(c.PostForm("session_id")),
+		AudioPath:  upload.path,
+		AudioData:  upload.data,
+		Voice:      c.PostForm("voice"),
+		FocusWords: parseFocusWords(c.PostForm("focus_words")),
+		Subtitle:   c.PostForm("subtitle"),
+	})
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
 
-	// 5. 保存文件到本地
-	if err := c.SaveUploadedFile(file, savePath); err != nil {
-		logrus.WithContext(ctx).Errorf("❌ 保存文件失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "系统保存文件失败"})
+	c.SSEvent("transcript", result)
+	c.Writer.Flush()
+	if stream == nil {
+		c.SSEvent("done", gin.H{"audio": false})
 		return
 	}
 
-	logrus.WithContext(ctx).Infof("✅ 语音文件上传成功: %s", savePath)
+	defer metrics.StreamStarted()()
+	c.Stream(func(w io.Writer) bool {
+		chunk, ok := <-stream.Chunks
+		if !ok {
+			return false
+		}
+		c.SSEvent("audio", base64.StdEncoding.EncodeToString(chunk))
+		return true
+	})
 
-	reply, err := h.chatService.ProcessVoiceChat(ctx, savePath)
-	logrus.WithContext(ctx).Info(reply)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI 处理失败: " + err.Error()})
+	if err := stream.Err(); err != nil {
+		logrus.WithContext(ctx).Errorf("❌ 流式合成中断: %v", err)
+		c.SSEvent("error", response.ErrorBody(c, service.TTSError(err)))
 		return
 	}
+	if subtitle := c.PostForm("subtitle"); subtitle != "" {
+		result.SetWords(subtitle, stream.Words())
+		c.SSEvent("subtitles", gin.H{"words": result.Words, "subtitles": result.Subtitles})
+	}
+	c.SSEvent("done", gin.H{"audio": true})
+}
+
+// uploadedAudio 校验通过的上传音频，data 和 path 只有一个不为空
+type uploadedAudio struct {
+	data []byte // 较小的音频放在内存中
+	path string // 较大的音频写到临时目录
+}
+
+// receiveAudio 校验上传的音频（请求体大小、格式、时长），失败时已经写好响应
+// 不超过 upload.memory_limit_bytes 的音频留在内存中，其余写到临时目录
+func (h *ChatHandler) receiveAudio(c *gin.Context) (*uploadedAudio, bool) {
+	ctx := c.Request.Context()
+	conf := h.upload
+
+	if conf.MaxBodyBytes > 0 {
+		if c.Request.ContentLength > conf.MaxBodyBytes {
+			response.Error(c, errcode.New(errcode.CodeUploadTooLarge).WithDetail("<= %dMB", conf.MaxBodyBytes>>20))
+			return nil, false
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, conf.MaxBodyBytes)
+	}
+
+	// 1. 获取上传的文件
+	file, err := c.FormFile("audio")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.Error(c, errcode.Wrap(errcode.CodeUploadTooLarge, err).WithDetail("<= %dMB", conf.MaxBodyBytes>>20))
+			return nil, false
+		}
+		response.Error(c, errcode.Wrap(errcode.CodeAudioMissing, err))
+		return nil, false
+	}
+	src, err := file.Open()
+	if err != nil {
+		response.Error(c, fmt.Errorf("读取上传文件失败: %w", err))
+		return nil, false
+	}
+	defer src.Close()
+
+	// 2. 按文件头判断格式，不信任文件名和 Content-Type
+	head := make([]byte, audio.HeaderSize)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		response.Error(c, fmt.Errorf("读取上传文件失败: %w", err))
+		return nil, false
+	}
+	head = head[:n]
+	info, err := audio.CheckWAV(head)
+	if err != nil {
+		logrus.WithContext(ctx).Warnf("拒绝上传的文件 %q: %v", file.Filename, err)
+		if errors.Is(err, audio.ErrNotAudio) {
+			response.Error(c, errcode.Wrap(errcode.CodeNotAudio, err))
+		} else {
+			response.Error(c, errcode.Wrap(errcode.CodeUnsupportedAudio, err))
+		}
+		return nil, false
+	}
+
+	// 3. 检查时长
+	duration := info.Duration(file.Size)
+	if limit := time.Duration(conf.MaxDurationSeconds) * time.Second; limit > 0 && duration > limit {
+		response.Error(c, errcode.New(errcode.CodeAudioTooLong).WithDetail("<= %ds", conf.MaxDurationSeconds))
+		return nil, false
+	}
 
+	body := io.MultiReader(bytes.NewReader(head), src)
+	// 4. 较小的音频直接放在内存中
+	if file.Size <= conf.MemoryLimitBytes {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			response.Error(c, fmt.Errorf("读取上传文件失败: %w", err))
+			return nil, false
+		}
+		logrus.WithContext(ctx).Infof("✅ 语音上传成功: %d 字节，时长 %v", len(data), duration)
+		return &uploadedAudio{data: data}, true
+	}
+
+	// 5. 较大的音频写到临时目录，文件名不使用客户端传来的名字
+	if err := os.MkdirAll(h.tempDir, os.ModePerm); err != nil {
+		response.Error(c, fmt.Errorf("创建临时目录失败: %w", err))
+		return nil, false
+	}
+	filename := fmt.Sprintf("%d_%s.wav", time.Now().Unix(), uuid.New().String()[:8])
+	savePath := filepath.Join(h.tempDir, filename)
+	if err := writeFile(savePath, body); err != nil {
+		response.Error(c, fmt.Errorf("保存文件失败: %w", err))
+		return nil, false
+	}
+
+	logrus.WithContext(ctx).Infof("✅ 语音文件上传成功: %s，时长 %v", savePath, duration)
+	return &uploadedAudio{path: savePath}, true
+}
+
+// writeFile 把 r 写入 path，失败时删除写了一半的文件
+func writeFile(path string, r io.Reader) error {
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, r); err != nil {
+		dst.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// parseSessionID 解析会话 ID，缺失或格式错误时开始新会话
+func parseSessionID(raw string) uint {
+	id, _ := strconv.ParseUint(raw, 10, 64)
+	return uint(id)
+}
+
+// parseFocusWords 解析教学词汇，格式为逗号分隔的单词，单词后可用冒号附带 CMU 音标
+// 例如：apple:ae1 p ah0 l,banana
+func parseFocusWords(raw string) []tts.FocusWord {
+	var words []tts.FocusWord
+	for _, item := range strings.Split(raw, ",") {
+		word, phoneme, _ := strings.Cut(item, ":")
+		word = strings.TrimSpace(word)
+		if word == "" {
+			continue
+		}
+		words = append(words, tts.FocusWord{Word: word, Phoneme: strings.TrimSpace(phoneme)})
+	}
+	return words
 }
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"oktalk/internal/model"
+	"oktalk/internal/pkg/constants"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/response"
+	"oktalk/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type ConversationHandler struct {
+	conversationService *service.ConversationService
+	parentService       *service.ParentService
+}
+
+func NewConversationHandler(conversationService *service.ConversationService, parentService *service.ParentService) *ConversationHandler {
+	return &ConversationHandler{
+		conversationService: conversationService,
+		parentService:       parentService,
+	}
+}
+
+// sessionListData 会话列表
+type sessionListData struct {
+	Total    int64                       `json:"total"`
+	Page     int                         `json:"page"`
+	Size     int                         `json:"size"`
+	Sessions []model.ConversationSession `json:"sessions"`
+}
+
+// turnData 一轮对话，音频以签名下载链接的形式返回，链接有效期见 storage.signed_url_ttl_seconds
+type turnData struct {
+	model.ConversationTurn
+	UserAudioURL  string `json:"user_audio_url,omitempty"`
+	ReplyAudioURL string `json:"reply_audio_url,omitempty"`
+}
+
+// sessionData 会话详情
+type sessionData struct {
+	Session *model.ConversationSession `json:"session"`
+	Turns   []turnData                 `json:"turns"`
+}
+
+// ListSessions 按时间倒序列出家长绑定的孩子的会话
+// 参数 user_id（可选，只看一个孩子）、page（从 1 开始）、size（最大 100）
+func (h *ConversationHandler) ListSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+	guardian := c.MustGet(constants.GuardianKey).(*service.Guardian)
+	var userID uint64
+	if raw := c.Query("user_id"); raw != "" {
+		var err error
+		if userID, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			response.Error(c, errcode.Wrap(errcode.CodeParamError, err).WithDetail("user_id"))
+			return
+		}
+		err = h.parentService.CheckChild(ctx, guardian, uint(userID))
+		if errors.Is(err, service.ErrChildNotFound) {
+			response.Error(c, errcode.Wrap(errcode.CodeChildNotFound, err))
+			return
+		}
+		if err != nil {
+			response.Error(c, fmt.Errorf("查询家长绑定关系失败: %w", err))
+			return
+		}
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 20
+	}
+
+	sessions, total, err := h.conversationService.ListSessions(ctx, guardian, uint(userID), page, size)
+	if err != nil {
+		response.Error(c, fmt.Errorf("查询会话列表失败: %w", err))
+		return
+	}
+	response.SendJSON(c, errcode.CodeSuccess, sessionListData{Total: total, Page: page, Size: size, Sessions: sessions}, "success")
+}
+
+// GetSession 回放一个会话：按顺序返回每一轮的文本、耗时和音频下载链接
+// 不是家长绑定的孩子的会话返回 404
+func (h *ConversationHandler) GetSession(c *gin.Context) {
+	ctx := c.Request.Context()
+	guardian := c.MustGet(constants.GuardianKey).(*service.Guardian)
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Error(c, errcode.New(errcode.CodeParamError).WithDetail("id"))
+		return
+	}
+
+	session, turns, err := h.conversationService.GetSession(ctx, guardian, uint(sessionID))
+	if errors.Is(err, service.ErrSessionNotFound) {
+		response.Error(c, errcode.Wrap(errcode.CodeSessionNotFound, err))
+		return
+	}
+	if err != nil {
+		response.Error(c, fmt.Errorf("查询会话失败: %w", err))
+		return
+	}
+
+	data := sessionData{Session: session, Turns: make([]turnData, 0, len(turns))}
+	for _, turn := range turns {
+		item := turnData{ConversationTurn: turn}
+		item.UserAudioURL = h.audioURL(c, turn.UserAudio)
+		item.ReplyAudioURL = h.audioURL(c, turn.ReplyAudio)
+		data.Turns = append(data.Turns, item)
+	}
+	response.SendJSON(c, errcode.CodeSuccess, data, "success")
+}
+
+// audioURL 生成音频的签名下载链接，没有保存音频或生成失败时返回空
+func (h *ConversationHandler) audioURL(c *gin.Context, key string) string {
+	if key == "" {
+		return ""
+	}
+	url, err := h.conversationService.AudioURL(c.Request.Context(), key)
+	if err != nil {
+		logrus.WithContext(c.Request.Context()).Warnf("生成音频下载链接失败: %v", err)
+		return ""
+	}
+	return url
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"time"
+
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/health"
+	"oktalk/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+	ready   func() bool // 启动完成且没有在退出
+	started time.Time
+}
+
+func NewHealthHandler(checker *health.Checker, ready func() bool) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+		ready:   ready,
+		started: time.Now(),
+	}
+}
+
+// livenessData 存活检查的返回数据
+type livenessData struct {
+	Status        string `json:"status"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
+
+// readinessData 就绪检查的返回数据
+type readinessData struct {
+	Ready    bool `json:"ready"`
+	Draining bool `json:"draining"` // 正在退出，不再接收新流量
+	health.Report
+}
+
+// Healthz 存活检查：进程能处理请求即返回成功，不检查依赖，避免依赖故障导致进程被反复重启
+func (h *HealthHandler) Healthz(c *gin.Context) {
+	response.SendJSON(c, errcode.CodeSuccess, livenessData{
+		Status:        health.StatusUp,
+		UptimeSeconds: int64(time.Since(h.started).Seconds()),
+	}, "success")
+}
+
+// Readyz 就绪检查：返回每个依赖的状态和耗时（结果有短暂缓存）
+// 正在退出或关键依赖（MySQL、Redis）不可用时返回 503
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	data := readinessData{Draining: !h.ready()}
+	data.Report = h.checker.Run(c.Request.Context())
+	data.Ready = !data.Draining && data.Status == health.StatusUp
+	if !data.Ready {
+		response.Error(c, errcode.New(errcode.CodeNotReady).WithData(data))
+		return
+	}
+	response.SendJSON(c, errcode.CodeSuccess, data, "success")
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"oktalk/internal/model"
+	"oktalk/internal/pkg/constants"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/response"
+	"oktalk/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ParentalHandler struct {
+	parentalService *service.ParentalService
+	parentService   *service.ParentService
+}
+
+func NewParentalHandler(parentalService *service.ParentalService, parentService *service.ParentService) *ParentalHandler {
+	return &ParentalHandler{
+		parentalService: parentalService,
+		parentService:   parentService,
+	}
+}
+
+// parentalControlRequest 家长设置，字段为零值（或空字符串）表示不限制
+type parentalControlRequest struct {
+	MaxDailyMinutes   int    `json:"max_daily_minutes"`
+	AllowedFrom       string `json:"allowed_from"` // HH:MM
+	AllowedTo         string `json:"allowed_to"`   // HH:MM
+	BreakEveryMinutes int    `json:"break_every_minutes"`
+	Timezone          string `json:"timezone"` // IANA 时区名，为空时使用服务端配置的时区
+}
+
+// GetControls 孩子（X-User-ID）查询自己的家长设置，只读
+func (h *ParentalHandler) GetControls(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	control, err := h.parentalService.Get(ctx, userID)
+	if err != nil {
+		response.Error(c, fmt.Errorf("查询家长设置失败: %w", err))
+		return
+	}
+	response.SendJSON(c, errcode.CodeSuccess, control, "success")
+}
+
+// GetChildControls 家长查询自己孩子（路径中的 user_id）的设置
+func (h *ParentalHandler) GetChildControls(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, ok := requireChild(c, h.parentService)
+	if !ok {
+		return
+	}
+	control, err := h.parentalService.Get(ctx, userID)
+	if err != nil {
+		response.Error(c, fmt.Errorf("查询家长设置失败: %w", err))
+		return
+	}
+	response.SendJSON(c, errcode.CodeSuccess, control, "success")
+}
+
+// UpdateControls 家长保存自己孩子（路径中的 user_id）的设置
+func (h *ParentalHandler) UpdateControls(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, ok := requireChild(c, h.parentService)
+	if !ok {
+		return
+	}
+	var req parentalControlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, errcode.Wrap(errcode.CodeParamError, err))
+		return
+	}
+
+	control := &model.ParentalControl{
+		UserID:            userID,
+		MaxDailyMinutes:   req.MaxDailyMinutes,
+		AllowedFrom:       req.AllowedFrom,
+		AllowedTo:         req.AllowedTo,
+		BreakEveryMinutes: req.BreakEveryMinutes,
+		Timezone:          req.Timezone,
+	}
+	err := h.parentalService.Save(ctx, control)
+	if err != nil {
+		response.Error(c, fmt.Errorf("保存家长设置失败: %w", err))
+		return
+	}
+	response.SendJSON(c, errcode.CodeSuccess, control, "success")
+}
+
+// requireUser 需要知道当前是哪个孩子的接口，缺少 X-User-ID 时已经写好响应
+func requireUser(c *gin.Context) (uint, bool) {
+	userID := c.GetUint(constants.UserIDKey)
+	if userID == 0 {
+		response.Error(c, errcode.New(errcode.CodeParamError).WithDetail("X-User-ID"))
+		return 0, false
+	}
+	return userID, true
+}
+
+// requireChild 家长接口：取路径中的 user_id，并确认当前家长（或管理员）可以访问这个孩子，失败时已经写好响应
+// 需要挂在 middleware.ParentAuth 之后
+func requireChild(c *gin.Context, parents *service.ParentService) (uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil || userID == 0 {
+		response.Error(c, errcode.New(errcode.CodeParamError).WithDetail("user_id"))
+		return 0, false
+	}
+	guardian := c.MustGet(constants.GuardianKey).(*service.Guardian)
+	err = parents.CheckChild(c.Request.Context(), guardian, uint(userID))
+	if errors.Is(err, service.ErrChildNotFound) {
+		response.Error(c, errcode.Wrap(errcode.CodeChildNotFound, err))
+		return 0, false
+	}
+	if err != nil {
+		response.Error(c, fmt.Errorf("查询家长绑定关系失败: %w", err))
+		return 0, false
+	}
+	return uint(userID), true
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 管理接口鉴权，请求头 X-Admin-Token 必须与配置的 admin_token 一致
+// 未配置 admin_token 时拒绝所有请求
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !validAdminToken(c, token) {
+			response.Error(c, errcode.New(errcode.CodeUnauthorized))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// validAdminToken 请求头 X-Admin-Token 是否与配置的 admin_token 一致，未配置时总是 false
+func validAdminToken(c *gin.Context, token string) bool {
+	got := c.GetHeader("X-Admin-Token")
+	return token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"oktalk/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponder 处理通过 c.Error 记录、但还没有写响应的错误，转换为统一的错误响应
+// handler 和中间件也可以直接调用 response.Error
+func ErrorResponder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		response.Error(c, c.Errors.Last().Err)
+	}
+}
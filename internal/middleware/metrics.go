@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"time"
+
+	"oktalk/internal/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 记录每个请求的耗时，按路由模板而不是实际路径划分，避免 ID 等参数造成标签爆炸
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTP(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"errors"
+
+	"oktalk/internal/pkg/constants"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/response"
+	"oktalk/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ParentAuth 家长接口鉴权，通过后在上下文中放入 *service.Guardian
+// X-Admin-Token 与配置的 admin_token 一致时按管理员放行，否则 X-Parent-Token 必须是已登记的家长令牌。
+// 家长身份与孩子自报的 X-User-ID 无关
+func ParentAuth(parents *service.ParentService, adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if validAdminToken(c, adminToken) {
+			c.Set(constants.GuardianKey, &service.Guardian{Admin: true})
+			c.Next()
+			return
+		}
+		parent, err := parents.Authenticate(c.Request.Context(), c.GetHeader("X-Parent-Token"))
+		if err != nil {
+			if !errors.Is(err, service.ErrInvalidParentToken) {
+				logrus.WithContext(c.Request.Context()).Errorf("查询家长令牌失败: %v", err)
+			}
+			response.Error(c, errcode.New(errcode.CodeUnauthorized))
+			c.Abort()
+			return
+		}
+		c.Set(constants.GuardianKey, &service.Guardian{ParentID: parent.ID})
+		c.Next()
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/constants"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/ratelimit"
+	"oktalk/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type rateCheck struct {
+	key  string
+	rule config.RateRule
+}
+
+// RateLimit 按用户和客户端 IP 限流，任意一个桶没有令牌就拒绝
+// 用户 ID 来自客户端自报的 X-User-ID，换一个 ID 就是一个新桶，所以 IP 桶总是生效（配置校验保证 ip 规则不为空）。
+// Redis 不可用时放行，限流故障不能影响孩子正常使用
+func RateLimit(limiter *ratelimit.Limiter, conf config.RateLimitConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !conf.Enabled {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+
+		checks := []rateCheck{{key: "ip:" + c.ClientIP(), rule: conf.IP}}
+		if userID := c.GetUint(constants.UserIDKey); userID != 0 {
+			checks = append(checks, rateCheck{key: fmt.Sprintf("user:%d", userID), rule: conf.User})
+		}
+
+		for _, check := range checks {
+			allowed, wait, err := limiter.Allow(ctx, check.key, check.rule)
+			if err != nil {
+				logrus.WithContext(ctx).Warnf("限流检查失败，放行请求: %v", err)
+				continue
+			}
+			if !allowed {
+				response.Error(c, errcode.New(errcode.CodeTooManyRequests).WithRetryAfter(wait))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -1,18 +1,18 @@
 package middleware
 
 import (
-	"fmt"
 	"net"
 	"net/http/httputil"
-	"oktalk/internal/pkg/constants"
 	"os"
 	"runtime/debug"
 	"strings"
 
+	"oktalk/internal/pkg/constants"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/response"
+
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
-
-	"oktalk/internal/pkg/response"
 )
 
 func RecoveryMiddleware() gin.HandlerFunc {
@@ -49,10 +49,8 @@ func RecoveryMiddleware() gin.HandlerFunc {
 				logrus.WithContext(ctx).Printf("[Panic Recover] trace_id: %s, err: %v\n%s\n%s",
 					traceID, err, string(httpRequest), string(stack))
 
-				// 4. 关键点：中断请求并返回带 TraceID 的 JSON
-				// 使用 AbortWithStatusJSON 确保后续的 Handler 不再执行
-				response.SendJSON(c, 500,
-					nil, fmt.Sprintf("服务器内部错误: %v", err))
+				// 4. 关键点：中断请求并返回带 TraceID 的 JSON（panic 的内容只写日志，不返回给客户端）
+				response.Error(c, errcode.New(errcode.CodeServerError))
 
 				c.Abort()
 			}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"oktalk/internal/pkg/constants"
+)
+
+// UserIdentity 识别当前请求的孩子
+// 接入账号体系之前由客户端通过 X-User-ID 请求头传入用户 ID，缺失或格式错误时为 0（匿名）
+// 这个 ID 没有经过认证，按它生效的家长控制、额度只和它一样可靠，匿名请求的处理见 parental.anonymous
+func UserIdentity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := strconv.ParseUint(c.GetHeader("X-User-ID"), 10, 64)
+		ctx := context.WithValue(c.Request.Context(), constants.UserIDKey, uint(userID))
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(constants.UserIDKey, uint(userID))
+		c.Next()
+	}
+}
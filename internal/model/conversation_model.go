@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// ConversationSession 一次连续的对话练习，由若干轮对话组成
+type ConversationSession struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"index" json:"user_id"`
+	TurnCount    int       `json:"turn_count"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ConversationSession) TableName() string {
+	return "conversation_session"
+}
+
+// ConversationTurn 一轮对话：孩子说的话和 AI 老师的回复
+type ConversationTurn struct {
+	ID        uint `gorm:"primaryKey" json:"id"`
+	SessionID uint `gorm:"index:idx_session_seq,priority:1" json:"session_id"`
+	Seq       int  `gorm:"index:idx_session_seq,priority:2" json:"seq"` // 在会话中的序号，从 1 开始
+	UserID    uint `gorm:"index" json:"user_id"`
+
+	UserText   string `gorm:"type:text" json:"user_text"`
+	UserAudio  string `gorm:"size:255" json:"-"` // 孩子录音在 BlobStore 中的 key
+	ReplyText  string `gorm:"type:text" json:"reply_text"`
+	ReplyAudio string `gorm:"size:255" json:"-"` // 回复语音在 BlobStore 中的 key，合成失败时为空
+	Model      string `gorm:"size:64" json:"model"`
+	Silent     bool   `json:"silent"`
+	Blocked    string `gorm:"size:16" json:"blocked,omitempty"`
+
+	// 各阶段耗时（毫秒）
+	ASRMs   int64 `json:"asr_ms"`
+	LLMMs   int64 `json:"llm_ms"`
+	TTSMs   int64 `json:"tts_ms"`
+	TotalMs int64 `json:"total_ms"`
+
+	// 发音评测分数，接入评测之前为空
+	SpeakingScore *float64 `gorm:"type:decimal(5,2)" json:"speaking_score"`
+	FluencyScore  *float64 `gorm:"type:decimal(5,2)" json:"fluency_score"`
+	AccuracyScore *float64 `gorm:"type:decimal(5,2)" json:"accuracy_score"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (ConversationTurn) TableName() string {
+	return "conversation_turn"
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Parent 家长账号，通过 X-Parent-Token 访问家长接口（家长设置、对话回放）
+// 令牌只在创建时返回一次，库里只保存它的 SHA-256
+type Parent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:64" json:"name"`
+	TokenHash string    `gorm:"size:64;uniqueIndex" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Parent) TableName() string {
+	return "parent"
+}
+
+// ParentChild 家长和孩子（X-User-ID）的绑定关系，家长只能查看和修改绑定的孩子
+type ParentChild struct {
+	ParentID  uint      `gorm:"primaryKey;autoIncrement:false" json:"parent_id"`
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false;index" json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (ParentChild) TableName() string {
+	return "parent_child"
+}
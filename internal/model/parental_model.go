@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// ParentalControl 家长为孩子设置的使用限制，字段为零值表示不限制
+type ParentalControl struct {
+	UserID          uint   `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	MaxDailyMinutes int    `json:"max_daily_minutes"`          // 每天最多使用的分钟数
+	AllowedFrom     string `gorm:"size:5" json:"allowed_from"` // 允许使用的开始时间，HH:MM
+	AllowedTo       string `gorm:"size:5" json:"allowed_to"`   // 允许使用的结束时间，HH:MM，早于开始时间表示跨零点
+	// BreakEveryMinutes 当天每累计使用这么多分钟，提醒孩子休息一下
+	BreakEveryMinutes int `json:"break_every_minutes"`
+	// Timezone 判断允许时段和“今天”的时区（IANA 名称，如 Asia/Shanghai），为空时使用 parental.timezone
+	Timezone  string    `gorm:"size:64" json:"timezone"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ParentalControl) TableName() string {
+	return "parental_control"
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// UserPlan 用户开通的套餐，没有记录的用户使用 quota.default_plan
+type UserPlan struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Plan      string    `gorm:"size:32" json:"plan"` // 对应 quota.plans 中的套餐名
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (UserPlan) TableName() string {
+	return "user_plan"
+}
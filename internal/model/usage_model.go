@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// 用量记录的服务类型
+const (
+	UsageProviderASR = "asr"
+	UsageProviderLLM = "llm"
+	UsageProviderTTS = "tts"
+)
+
+// UsageRecord 一次 AI 服务调用的用量和成本
+// 不同服务只填写各自的计量字段：LLM 为 token 数，ASR 为音频秒数，TTS 为字符数
+type UsageRecord struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	UserID           uint      `gorm:"index" json:"user_id"`
+	TraceID          string    `gorm:"size:32;index" json:"trace_id"`
+	Provider         string    `gorm:"size:16;index" json:"provider"` // asr / llm / tts
+	Model            string    `gorm:"size:64" json:"model"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	AudioSeconds     int       `json:"audio_seconds"`
+	Characters       int       `json:"characters"`
+	Cost             float64   `gorm:"type:decimal(12,6)" json:"cost"` // 按 pricing 配置计算的成本
+	CreatedAt        time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (UsageRecord) TableName() string {
+	return "usage_record"
+}
@@ -3,95 +3,217 @@ package asr
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/dashscope"
+	"oktalk/internal/pkg/trace"
 	"os"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 type AliyunASR struct {
 	conf *config.AliyunConfig
+	pool *dashscope.Pool
 }
 
-var dialer = websocket.DefaultDialer
 var wsURL string
 var asrModel string
 var apiKey string
 
-func NewAliyunASR(conf *config.AliyunConfig) *AliyunASR {
+// 没有配置超时时使用的默认值
+const (
+	defaultStartTimeout  = 10 * time.Second // 等待 task-started
+	defaultResultTimeout = 30 * time.Second // 发送完音频后等待识别结果
+)
+
+// seconds 配置的秒数，未配置时使用默认值
+func seconds(n int, def time.Duration) time.Duration {
+	if n <= 0 {
+		return def
+	}
+	return time.Duration(n) * time.Second
+}
+
+func NewAliyunASR(conf *config.AliyunConfig, pool *dashscope.Pool) *AliyunASR {
 	wsURL = conf.ASR.WsURL
 	asrModel = conf.ASR.Model
 	apiKey = conf.DASHSCOPE_API_KEY
-	return &AliyunASR{conf: conf}
+	return &AliyunASR{conf: conf, pool: pool}
 }
 
 // RecognizeOnce 对应你示例中的逻辑，但进行了工程化封装
 func (a *AliyunASR) RecognizeOnce(ctx context.Context, audioPath string) (string, error) {
-	// 连接websocket服务
-	conn, err := connectWebSocket(apiKey)
-	if err != nil {
-		return "", fmt.Errorf("连接 WebSocket 失败: %w", err)
+	result, err := a.Recognize(ctx, audioPath)
+	if err != nil || result == nil {
+		return "", err
 	}
-	defer closeConnection(conn)
+	return result.Text, nil
+}
 
-	// 发送run-task指令
-	taskID, err := sendRunTaskCmd(conn)
+// Recognize 识别完整音频文件，返回拼接文本和逐句结果
+// 文件按配置的 pacing 发送，默认不限速
+func (a *AliyunASR) Recognize(ctx context.Context, audioPath string) (*Result, error) {
+	file, err := os.Open(audioPath)
 	if err != nil {
-		return "", fmt.Errorf("发送 run-task 失败: %w", err)
+		return nil, fmt.Errorf("打开音频文件失败: %w", err)
 	}
+	defer file.Close()
 
-	// 3. 启动结果接收器
-	resultChan := make(chan string, 1)
-	errorChan := make(chan error, 1)
-	taskStarted := make(chan bool, 1)
+	pacing := Pacing(a.conf.ASR.Pacing)
+	if pacing == "" {
+		pacing = PacingNone
+	}
+	return a.RecognizeReader(ctx, file, pacing)
+}
 
-	// 启动一个goroutine来接受websocket结果
-	go receiveResults(ctx, conn, resultChan, errorChan, taskStarted)
+// RecognizeReader 识别一段音频流，读到 EOF 后结束任务
+// 实时录音流使用 PacingRealtime，已经在内存或磁盘上的完整音频使用 PacingNone
+func (a *AliyunASR) RecognizeReader(ctx context.Context, r io.Reader, pacing Pacing) (result *Result, err error) {
+	ctx, span := trace.Tracer("asr").Start(ctx, "asr.recognize", oteltrace.WithAttributes(
+		trace.AttrModel.String(a.conf.ASR.Model),
+		attribute.String("asr.pacing", string(pacing)),
+	))
+	defer func() {
+		if err != nil {
+			var taskErr *TaskError
+			code := ""
+			if errors.As(err, &taskErr) {
+				code = taskErr.Code
+			}
+			trace.Fail(span, err, code)
+		} else {
+			span.SetAttributes(
+				attribute.Int("asr.audio_duration_ms", result.Duration),
+				attribute.Int("asr.billed_seconds", result.BilledSeconds),
+				attribute.Int("asr.sentences", len(result.Sentences)),
+			)
+		}
+		span.End()
+	}()
 
-	// 4. 等待 task-started
-	select {
-	case <-ctx.Done():
-		logrus.WithContext(ctx).Warnf("")
-	case <-taskStarted:
-		// 任务启动成功
-		logrus.WithContext(ctx).Info("✅ 任务启动成功")
-	case err := <-errorChan:
-		return "", err
-	case <-time.After(10 * time.Second):
-		return "", fmt.Errorf("等待 task-started 超时")
+	// 1~4. 从连接池取连接并启动识别任务
+	task, err := a.startTask(ctx)
+	if err != nil {
+		return nil, err
 	}
+	// 只有完整收到 task-finished 的连接才放回连接池
+	finished := false
+	defer func() {
+		if finished {
+			task.conn.Release()
+		} else {
+			task.conn.Discard()
+		}
+	}()
 
 	// 5.发送音频数据
-	err = sendAudioData(ctx, conn, audioPath)
+	logrus.WithContext(ctx).Infof("发送音频数据（pacing=%s）", pacing)
+	err = sendAudio(ctx, task.conn.Conn, r, a.conf.ASR.ChunkSize, pacing)
 	if err != nil {
-		return "", fmt.Errorf("发送音频数据失败: %w", err)
+		return nil, fmt.Errorf("发送音频数据失败: %w", err)
 	}
 
 	// 6.发送完音频后，发送 finish-task 指令
-	err = sendFinishTaskCmd(ctx, conn, taskID)
+	err = sendFinishTaskCmd(ctx, task.conn.Conn, task.id)
 	if err != nil {
-		return "", fmt.Errorf("发送 finish-task 失败: %w", err)
+		return nil, fmt.Errorf("发送 finish-task 失败: %w", err)
 	}
 	// 7. 等待识别结果
 	select {
 	case <-ctx.Done():
 		logrus.WithContext(ctx).Warningf("等待识别结果 %v", ctx.Err())
-		return "", nil
-	case text := <-resultChan:
-		logrus.WithContext(ctx).Infof("识别到结果: %s", text)
-		return text, nil
-	case err = <-errorChan:
+		return nil, ctx.Err()
+	case result := <-task.resultChan:
+		finished = true
+		logrus.WithContext(ctx).Infof("识别到结果: %s（共 %d 句）", result.Text, len(result.Sentences))
+		return result, nil
+	case err = <-task.errorChan:
 		logrus.WithContext(ctx).Errorf("等待识别结果遇到错误：%v", err)
-		return "", nil
-	case <-time.After(30 * time.Second):
+		return nil, err
+	case <-time.After(seconds(a.conf.ASR.ResultTimeoutSeconds, defaultResultTimeout)):
 		logrus.WithContext(ctx).Warningf("等待识别结果超时")
-		return "", nil
+		return nil, fmt.Errorf("%w: 等待识别结果超时", ErrTimeout)
+	}
+}
+
+// recognitionTask 一个已经收到 task-started 的识别任务
+type recognitionTask struct {
+	id         string
+	conn       *dashscope.Conn
+	resultChan chan *Result
+	errorChan  chan error
+}
+
+// startTask 取连接、发送 run-task 并等待 task-started
+// 复用的空闲连接可能已被服务端断开，这种情况下换一条新连接重试一次
+func (a *AliyunASR) startTask(ctx context.Context) (*recognitionTask, error) {
+	for attempt := 0; ; attempt++ {
+		task, reused, err := a.tryStartTask(ctx)
+		if err == nil {
+			return task, nil
+		}
+		if !reused || attempt > 0 || ctx.Err() != nil {
+			return nil, err
+		}
+		logrus.WithContext(ctx).Warnf("复用的 WebSocket 连接不可用，重新建立连接: %v", err)
+	}
+}
+
+func (a *AliyunASR) tryStartTask(ctx context.Context) (*recognitionTask, bool, error) {
+	// 连接websocket服务
+	conn, resp, err := a.pool.Get(ctx, endpoint())
+	if err != nil {
+		return nil, false, fmt.Errorf("连接 WebSocket 失败: %w", classifyHandshake(resp, err))
+	}
+
+	// 发送run-task指令
+	taskID, err := sendRunTaskCmd(conn.Conn)
+	if err != nil {
+		conn.Discard()
+		return nil, conn.Reused(), fmt.Errorf("发送 run-task 失败: %w", err)
+	}
+
+	// 3. 启动结果接收器
+	task := &recognitionTask{
+		id:         taskID,
+		conn:       conn,
+		resultChan: make(chan *Result, 1),
+		errorChan:  make(chan error, 1),
+	}
+	taskStarted := make(chan bool, 1)
+
+	// 启动一个goroutine来接受websocket结果
+	go receiveResults(ctx, conn.Conn, task.resultChan, task.errorChan, taskStarted)
+
+	// 4. 等待 task-started
+	select {
+	case <-ctx.Done():
+		logrus.WithContext(ctx).Warnf("等待 task-started 时请求已取消: %v", ctx.Err())
+		conn.Discard()
+		return nil, false, ctx.Err()
+	case <-taskStarted:
+		// 任务启动成功
+		logrus.WithContext(ctx).Info("✅ 任务启动成功")
+		span := oteltrace.SpanFromContext(ctx)
+		span.SetAttributes(trace.AttrTaskID.String(taskID))
+		span.AddEvent(trace.EventTaskStarted)
+		return task, false, nil
+	case err := <-task.errorChan:
+		conn.Discard()
+		// 服务端明确返回 task-failed 说明连接是好的，不需要重试
+		var taskErr *TaskError
+		return nil, conn.Reused() && !errors.As(err, &taskErr), err
+	case <-time.After(seconds(a.conf.ASR.StartTimeoutSeconds, defaultStartTimeout)):
+		conn.Discard()
+		return nil, false, fmt.Errorf("%w: 等待 task-started 超时", ErrTimeout)
 	}
 }
 
@@ -107,17 +229,21 @@ type Header struct {
 }
 
 type Output struct {
-	Sentence struct {
-		BeginTime int64  `json:"begin_time"`
-		EndTime   *int64 `json:"end_time"`
-		Text      string `json:"text"`
-		Words     []struct {
-			BeginTime   int64  `json:"begin_time"`
-			EndTime     *int64 `json:"end_time"`
-			Text        string `json:"text"`
-			Punctuation string `json:"punctuation"`
-		} `json:"words"`
-	} `json:"sentence"`
+	Sentence OutputSentence `json:"sentence"`
+}
+
+type OutputSentence struct {
+	BeginTime   int64  `json:"begin_time"`
+	EndTime     *int64 `json:"end_time"`
+	Text        string `json:"text"`
+	Heartbeat   bool   `json:"heartbeat"`
+	SentenceEnd bool   `json:"sentence_end"`
+	Words       []struct {
+		BeginTime   int64  `json:"begin_time"`
+		EndTime     *int64 `json:"end_time"`
+		Text        string `json:"text"`
+		Punctuation string `json:"punctuation"`
+	} `json:"words"`
 }
 
 type Payload struct {
@@ -148,17 +274,17 @@ type Event struct {
 	Payload Payload `json:"payload"`
 }
 
-// 连接WebSocket服务
-func connectWebSocket(apiKey string) (*websocket.Conn, error) {
-	header := make(http.Header)
-	header.Add("Authorization", fmt.Sprintf("bearer %s", apiKey))
-	conn, _, err := dialer.Dial(wsURL, header)
-	return conn, err
+// endpoint WebSocket 服务地址和鉴权头
+func endpoint() dashscope.Endpoint {
+	return dashscope.NewEndpoint(wsURL, apiKey)
 }
 
 // receiveResults 接收 WebSocket 结果
-func receiveResults(ctx context.Context, conn *websocket.Conn, resultChan chan<- string, errorChan chan<- error, taskStarted chan<- bool) {
-	var finalText string
+func receiveResults(ctx context.Context, conn *websocket.Conn, resultChan chan<- *Result, errorChan chan<- error, taskStarted chan<- bool) {
+	t := newTranscript()
+	billed := 0
+	span := oteltrace.SpanFromContext(ctx)
+	gotResult := false
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
@@ -172,20 +298,30 @@ func receiveResults(ctx context.Context, conn *websocket.Conn, resultChan chan<-
 			logrus.WithContext(ctx).Warnf("解析事件失败：%v", err)
 			continue
 		}
+		if event.Payload.Usage != nil {
+			billed = event.Payload.Usage.Duration
+		}
 		switch event.Header.Event {
 		case "task-started":
 			taskStarted <- true
 
 		case "result-generated":
-			// 从 output.sentence.text 提取识别结果
-			text := event.Payload.Output.Sentence.Text
-			if text != "" {
-				finalText = text
-				logrus.WithContext(ctx).Info("✅ 识别结果：%s", finalText)
+			// 按 begin_time 合并同一句话的中间结果，sentence_end 时定稿
+			sentence := event.Payload.Output.Sentence
+			t.apply(sentence)
+			if !gotResult && !sentence.Heartbeat && sentence.Text != "" {
+				gotResult = true
+				span.AddEvent(trace.EventFirstResult)
+			}
+			if sentence.SentenceEnd {
+				logrus.WithContext(ctx).Infof("✅ 识别结果：%s", sentence.Text)
 			}
 
 		case "task-finished":
-			resultChan <- finalText
+			span.AddEvent(trace.EventTaskFinished)
+			result := t.result()
+			result.BilledSeconds = billed
+			resultChan <- result
 			return
 
 		case "task-failed":
@@ -193,7 +329,11 @@ func receiveResults(ctx context.Context, conn *websocket.Conn, resultChan chan<-
 			if errorMsg == "" {
 				errorMsg = "ASR 任务失败"
 			}
-			errorChan <- fmt.Errorf(errorMsg)
+			errorChan <- &TaskError{
+				TaskID:  event.Header.TaskID,
+				Code:    event.Header.ErrorCode,
+				Message: errorMsg,
+			}
 			return
 		}
 	}
@@ -235,40 +375,6 @@ func generateRunTaskCmd() (string, string, error) {
 	return string(runTaskCmdJSON), taskID, err
 }
 
-// 发送音频数据
-func sendAudioData(ctx context.Context, conn *websocket.Conn, audioPath string) error {
-	select {
-	case <-ctx.Done():
-		logrus.WithContext(ctx).Warningf("")
-		return ctx.Err()
-	default:
-		logrus.WithContext(ctx).Infof("发送音频数据")
-	}
-
-	file, err := os.Open(audioPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	buf := make([]byte, 1024)
-	for {
-		n, err := file.Read(buf)
-		if n == 0 {
-			break
-		}
-		if err != nil && err != io.EOF {
-			return err
-		}
-		err = conn.WriteMessage(websocket.BinaryMessage, buf[:n])
-		if err != nil {
-			return err
-		}
-		time.Sleep(50 * time.Millisecond)
-	}
-	return nil
-}
-
 // 发送finish-task指令
 func sendFinishTaskCmd(ctx context.Context, conn *websocket.Conn, taskID string) error {
 	finishTaskCmd, err := generateFinishTaskCmd(taskID)
@@ -300,10 +406,3 @@ func generateFinishTaskCmd(taskID string) (string, error) {
 	finishTaskCmdJSON, err := json.Marshal(finishTaskCmd)
 	return string(finishTaskCmdJSON), err
 }
-
-// 关闭连接
-func closeConnection(conn *websocket.Conn) {
-	if conn != nil {
-		conn.Close()
-	}
-}
@@ -1,12 +1,26 @@
 package asr
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 // Result ASR 识别结果
 type Result struct {
-	Text     string // 最终识别的文本
+	Text     string // 最终识别的文本（所有句子按时间顺序拼接）
 	IsFinal  bool   // 是否是最终结果
 	Duration int    // 音频时长（毫秒）
+	// BilledSeconds 服务端计费的音频时长（秒），来自 task-finished 事件的 usage.duration
+	BilledSeconds int
+	Sentences     []Sentence // 逐句识别结果，按 BeginTime 升序
+}
+
+// Sentence 单句识别结果
+type Sentence struct {
+	BeginTime int64  // 句子开始时间（毫秒）
+	EndTime   int64  // 句子结束时间（毫秒），未结束时为 0
+	Text      string // 句子文本
+	IsFinal   bool   // 是否已收到 sentence_end
 }
 
 // ASRService ASR 服务接口
@@ -15,6 +29,12 @@ type ASRService interface {
 	// 这是处理流式语音的核心逻辑
 	// RecognizeStream(ctx context.Context) (dataChan chan []byte, errChan chan error, resChan chan Result, err error)
 
-	// RecognizeOnce 处理已经录好的完整文件（PRD 中的简单模式）
+	// RecognizeOnce 处理已经录好的完整文件（PRD 中的简单模式），只返回拼接后的文本
 	RecognizeOnce(ctx context.Context, audioPath string) (string, error)
+
+	// Recognize 处理已经录好的完整文件，返回拼接文本和逐句结果
+	Recognize(ctx context.Context, audioPath string) (*Result, error)
+
+	// RecognizeReader 识别一段音频流（16kHz wav），pacing 决定发送节奏
+	RecognizeReader(ctx context.Context, r io.Reader, pacing Pacing) (*Result, error)
 }
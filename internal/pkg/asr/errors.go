@@ -0,0 +1,71 @@
+package asr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 识别失败的错误分类，调用方使用 errors.Is 判断
+var (
+	ErrTimeout       = errors.New("asr: 识别超时")
+	ErrAuthFailed    = errors.New("asr: 鉴权失败")
+	ErrQuotaExceeded = errors.New("asr: 调用额度不足或被限流")
+	ErrBadAudio      = errors.New("asr: 音频无法识别")
+	ErrTaskFailed    = errors.New("asr: 识别任务失败")
+)
+
+// TaskError 服务端 task-failed 事件携带的错误信息
+type TaskError struct {
+	TaskID  string
+	Code    string // header.error_code
+	Message string // header.error_message
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("asr task %s failed: [%s] %s", e.TaskID, e.Code, e.Message)
+}
+
+// Unwrap 根据 error_code 归类到上面的哨兵错误
+func (e *TaskError) Unwrap() error {
+	return classifyErrorCode(e.Code)
+}
+
+// classifyErrorCode DashScope 的 error_code 分类
+// 参考：https://help.aliyun.com/zh/model-studio/error-code
+func classifyErrorCode(code string) error {
+	switch {
+	case code == "":
+		return ErrTaskFailed
+	case strings.HasPrefix(code, "InvalidApiKey"),
+		strings.HasPrefix(code, "AccessDenied"),
+		strings.HasPrefix(code, "Unauthorized"):
+		return ErrAuthFailed
+	case strings.HasPrefix(code, "Throttling"),
+		strings.HasPrefix(code, "Arrearage"),
+		strings.Contains(code, "Quota"):
+		return ErrQuotaExceeded
+	case strings.HasPrefix(code, "InvalidFile"),
+		strings.HasPrefix(code, "UnsupportedFormat"),
+		strings.Contains(code, "Audio"):
+		return ErrBadAudio
+	default:
+		return ErrTaskFailed
+	}
+}
+
+// classifyHandshake 建立 WebSocket 连接时的 HTTP 状态码分类
+func classifyHandshake(resp *http.Response, err error) error {
+	if resp == nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %v", ErrAuthFailed, err)
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %v", ErrQuotaExceeded, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,77 @@
+package asr
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Pacing 音频发送节奏
+type Pacing string
+
+const (
+	// PacingNone 不限速，读到多少发多少。适合已经录好的文件
+	PacingNone Pacing = "none"
+	// PacingRealtime 按音频时长匀速发送。适合实时录音流，或需要模拟实时输入的场景
+	PacingRealtime Pacing = "realtime"
+)
+
+const (
+	// defaultChunkSize 默认每帧 3200 字节，即 16kHz 16bit 单声道的 100ms 音频
+	defaultChunkSize = 3200
+	// bytesPerMillisecond 16kHz 16bit 单声道每毫秒的字节数
+	bytesPerMillisecond = 16000 * 2 / 1000
+)
+
+// sendAudio 分帧发送音频
+// 实时模式下按“已发送字节对应的音频时长”对齐墙钟时间，而不是每帧固定 sleep，避免误差累积
+func sendAudio(ctx context.Context, conn *websocket.Conn, r io.Reader, chunkSize int, pacing Pacing) error {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	buf := make([]byte, chunkSize)
+	start := time.Now()
+	sent := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if werr := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+				return werr
+			}
+			sent += n
+			if pacing == PacingRealtime {
+				due := start.Add(time.Duration(sent/bytesPerMillisecond) * time.Millisecond)
+				if err := sleepUntil(ctx, due); err != nil {
+					return err
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package asr
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// benchAudioMs 基准测试使用的录音时长
+const benchAudioMs = 1000
+
+// firstResultAfterMs 假服务端收到多长的音频后返回第一个识别结果
+const firstResultAfterMs = 300
+
+// fixedWAV 生成一段固定的 16kHz 单声道 16 位 PCM WAV（440Hz 正弦波）
+func fixedWAV(ms int) []byte {
+	samples := 16000 * ms / 1000
+	var buf bytes.Buffer
+	write := func(v any) { _ = binary.Write(&buf, binary.LittleEndian, v) }
+	buf.WriteString("RIFF")
+	write(uint32(36 + samples*2))
+	buf.WriteString("WAVEfmt ")
+	write(uint32(16))
+	write(uint16(1))     // PCM
+	write(uint16(1))     // 单声道
+	write(uint32(16000)) // 采样率
+	write(uint32(32000)) // 字节率
+	write(uint16(2))     // 块对齐
+	write(uint16(16))    // 位深
+	buf.WriteString("data")
+	write(uint32(samples * 2))
+	for i := 0; i < samples; i++ {
+		write(int16(8000 * math.Sin(2*math.Pi*440*float64(i)/16000)))
+	}
+	return buf.Bytes()
+}
+
+// newFakeASRServer 收到 firstResultAfterMs 的音频后推送一条 result-generated，模拟识别服务的首包
+func newFakeASRServer(b *testing.B) string {
+	b.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		received, notified := 0, false
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received += len(data)
+			if !notified && received >= firstResultAfterMs*bytesPerMillisecond {
+				notified = true
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"header":{"event":"result-generated"}}`))
+			}
+		}
+	}))
+	b.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// BenchmarkSendAudio 对比不限速和实时发送同一段录音：
+// first-result-ms 是开始发送到收到第一个识别结果的时间，send-ms 是发送完整段音频的时间
+func BenchmarkSendAudio(b *testing.B) {
+	audio := fixedWAV(benchAudioMs)
+	url := newFakeASRServer(b)
+
+	for _, pacing := range []Pacing{PacingNone, PacingRealtime} {
+		b.Run(string(pacing), func(b *testing.B) {
+			var firstResult, send time.Duration
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+				if err != nil {
+					b.Fatalf("连接假服务端失败: %v", err)
+				}
+				got := make(chan time.Time, 1)
+				go func() {
+					if _, _, err := conn.ReadMessage(); err == nil {
+						got <- time.Now()
+					}
+				}()
+				b.StartTimer()
+
+				start := time.Now()
+				if err := sendAudio(context.Background(), conn, bytes.NewReader(audio), defaultChunkSize, pacing); err != nil {
+					b.Fatalf("sendAudio: %v", err)
+				}
+				send += time.Since(start)
+				select {
+				case at := <-got:
+					firstResult += at.Sub(start)
+				case <-time.After(5 * time.Second):
+					b.Fatal("没有收到识别结果")
+				}
+
+				b.StopTimer()
+				conn.Close()
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(firstResult)/float64(time.Millisecond)/float64(b.N), "first-result-ms")
+			b.ReportMetric(float64(send)/float64(time.Millisecond)/float64(b.N), "send-ms")
+		})
+	}
+}
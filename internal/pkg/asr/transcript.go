@@ -0,0 +1,90 @@
+package asr
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// transcript 按句子边界组装 result-generated 事件
+// 同一句话的中间结果会多次推送（begin_time 相同），收到 sentence_end 后该句才算最终结果
+type transcript struct {
+	sentences map[int64]*Sentence // key: begin_time
+}
+
+func newTranscript() *transcript {
+	return &transcript{sentences: make(map[int64]*Sentence)}
+}
+
+// apply 合并一次 result-generated 事件中的句子
+func (t *transcript) apply(s OutputSentence) {
+	// 心跳包不携带识别内容
+	if s.Heartbeat {
+		return
+	}
+	cur, ok := t.sentences[s.BeginTime]
+	if !ok {
+		cur = &Sentence{BeginTime: s.BeginTime}
+		t.sentences[s.BeginTime] = cur
+	}
+	// 已经结束的句子不再被迟到的中间结果覆盖
+	if cur.IsFinal {
+		return
+	}
+	if s.Text != "" {
+		cur.Text = s.Text
+	}
+	if s.EndTime != nil {
+		cur.EndTime = *s.EndTime
+	}
+	cur.IsFinal = s.SentenceEnd
+}
+
+// result 生成最终结果：按开始时间排序，丢弃空句子并拼接文本
+func (t *transcript) result() *Result {
+	sentences := make([]Sentence, 0, len(t.sentences))
+	for _, s := range t.sentences {
+		if strings.TrimSpace(s.Text) == "" {
+			continue
+		}
+		sentences = append(sentences, *s)
+	}
+	sort.Slice(sentences, func(i, j int) bool {
+		return sentences[i].BeginTime < sentences[j].BeginTime
+	})
+
+	var (
+		sb       strings.Builder
+		duration int64
+		isFinal  = true
+	)
+	for _, s := range sentences {
+		sb.WriteString(joinSeparator(sb.String(), s.Text))
+		sb.WriteString(s.Text)
+		if s.EndTime > duration {
+			duration = s.EndTime
+		}
+		if !s.IsFinal {
+			isFinal = false
+		}
+	}
+	return &Result{
+		Text:      sb.String(),
+		IsFinal:   isFinal,
+		Duration:  int(duration),
+		Sentences: sentences,
+	}
+}
+
+// joinSeparator 英文句子之间补一个空格，中文句子直接拼接
+func joinSeparator(prev, next string) string {
+	if prev == "" || next == "" {
+		return ""
+	}
+	last, _ := utf8.DecodeLastRuneInString(prev)
+	first, _ := utf8.DecodeRuneInString(next)
+	if last < utf8.RuneSelf && first < utf8.RuneSelf && last != ' ' && first != ' ' {
+		return " "
+	}
+	return ""
+}
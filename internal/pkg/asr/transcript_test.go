@@ -0,0 +1,143 @@
+package asr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// replay 依次把录制的 result-generated 事件交给 transcript，返回最终结果
+func replay(t *testing.T, events []string) *Result {
+	t.Helper()
+	tr := newTranscript()
+	for i, raw := range events {
+		var event Event
+		if err := json.Unmarshal([]byte(raw), &event); err != nil {
+			t.Fatalf("解析第 %d 个事件失败: %v", i, err)
+		}
+		if event.Header.Event != "result-generated" {
+			t.Fatalf("第 %d 个事件不是 result-generated: %s", i, event.Header.Event)
+		}
+		tr.apply(event.Payload.Output.Sentence)
+	}
+	return tr.result()
+}
+
+func TestTranscriptRecordedSequences(t *testing.T) {
+	tests := []struct {
+		name      string
+		events    []string
+		text      string
+		sentences []Sentence
+		isFinal   bool
+		duration  int
+	}{
+		{
+			name: "中间结果被同一句的后续结果替换",
+			events: []string{
+				`{"header":{"event":"result-generated","task_id":"t1"},"payload":{"output":{"sentence":{"begin_time":170,"end_time":null,"text":"Hello","sentence_end":false}}}}`,
+				`{"header":{"event":"result-generated","task_id":"t1"},"payload":{"output":{"sentence":{"begin_time":170,"end_time":null,"text":"Hello wor","sentence_end":false}}}}`,
+				`{"header":{"event":"result-generated","task_id":"t1"},"payload":{"output":{"sentence":{"begin_time":170,"end_time":1920,"text":"Hello world.","sentence_end":true}}}}`,
+			},
+			text:      "Hello world.",
+			sentences: []Sentence{{BeginTime: 170, EndTime: 1920, Text: "Hello world.", IsFinal: true}},
+			isFinal:   true,
+			duration:  1920,
+		},
+		{
+			name: "已结束的句子不被迟到的中间结果覆盖",
+			events: []string{
+				`{"header":{"event":"result-generated","task_id":"t2"},"payload":{"output":{"sentence":{"begin_time":0,"end_time":1500,"text":"I like cats.","sentence_end":true}}}}`,
+				`{"header":{"event":"result-generated","task_id":"t2"},"payload":{"output":{"sentence":{"begin_time":0,"end_time":null,"text":"I like ca","sentence_end":false}}}}`,
+			},
+			text:      "I like cats.",
+			sentences: []Sentence{{BeginTime: 0, EndTime: 1500, Text: "I like cats.", IsFinal: true}},
+			isFinal:   true,
+			duration:  1500,
+		},
+		{
+			name: "begin_time 乱序到达时按时间排序拼接",
+			events: []string{
+				`{"header":{"event":"result-generated","task_id":"t3"},"payload":{"output":{"sentence":{"begin_time":2400,"end_time":null,"text":"How are","sentence_end":false}}}}`,
+				`{"header":{"event":"result-generated","task_id":"t3"},"payload":{"output":{"sentence":{"begin_time":100,"end_time":1800,"text":"Good morning.","sentence_end":true}}}}`,
+				`{"header":{"event":"result-generated","task_id":"t3"},"payload":{"output":{"sentence":{"begin_time":2400,"end_time":3900,"text":"How are you?","sentence_end":true}}}}`,
+			},
+			text: "Good morning. How are you?",
+			sentences: []Sentence{
+				{BeginTime: 100, EndTime: 1800, Text: "Good morning.", IsFinal: true},
+				{BeginTime: 2400, EndTime: 3900, Text: "How are you?", IsFinal: true},
+			},
+			isFinal:  true,
+			duration: 3900,
+		},
+		{
+			name: "心跳和空句子不计入结果，中文句子直接拼接",
+			events: []string{
+				`{"header":{"event":"result-generated","task_id":"t4"},"payload":{"output":{"sentence":{"begin_time":0,"end_time":null,"text":"","heartbeat":true,"sentence_end":false}}}}`,
+				`{"header":{"event":"result-generated","task_id":"t4"},"payload":{"output":{"sentence":{"begin_time":0,"end_time":1200,"text":"你好。","sentence_end":true}}}}`,
+				`{"header":{"event":"result-generated","task_id":"t4"},"payload":{"output":{"sentence":{"begin_time":1300,"end_time":1400,"text":"  ","sentence_end":true}}}}`,
+				`{"header":{"event":"result-generated","task_id":"t4"},"payload":{"output":{"sentence":{"begin_time":1500,"end_time":2600,"text":"我想学英语。","sentence_end":true}}}}`,
+			},
+			text: "你好。我想学英语。",
+			sentences: []Sentence{
+				{BeginTime: 0, EndTime: 1200, Text: "你好。", IsFinal: true},
+				{BeginTime: 1500, EndTime: 2600, Text: "我想学英语。", IsFinal: true},
+			},
+			isFinal:  true,
+			duration: 2600,
+		},
+		{
+			name: "最后一句没有收到 sentence_end",
+			events: []string{
+				`{"header":{"event":"result-generated","task_id":"t5"},"payload":{"output":{"sentence":{"begin_time":0,"end_time":900,"text":"Yes.","sentence_end":true}}}}`,
+				`{"header":{"event":"result-generated","task_id":"t5"},"payload":{"output":{"sentence":{"begin_time":1000,"end_time":null,"text":"I want","sentence_end":false}}}}`,
+			},
+			text: "Yes. I want",
+			sentences: []Sentence{
+				{BeginTime: 0, EndTime: 900, Text: "Yes.", IsFinal: true},
+				{BeginTime: 1000, Text: "I want"},
+			},
+			isFinal:  false,
+			duration: 900,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := replay(t, tt.events)
+			if got.Text != tt.text {
+				t.Errorf("Text = %q, want %q", got.Text, tt.text)
+			}
+			if got.IsFinal != tt.isFinal {
+				t.Errorf("IsFinal = %v, want %v", got.IsFinal, tt.isFinal)
+			}
+			if got.Duration != tt.duration {
+				t.Errorf("Duration = %d, want %d", got.Duration, tt.duration)
+			}
+			if len(got.Sentences) != len(tt.sentences) {
+				t.Fatalf("Sentences = %+v, want %+v", got.Sentences, tt.sentences)
+			}
+			for i := range tt.sentences {
+				if got.Sentences[i] != tt.sentences[i] {
+					t.Errorf("Sentences[%d] = %+v, want %+v", i, got.Sentences[i], tt.sentences[i])
+				}
+			}
+		})
+	}
+}
+
+func TestJoinSeparator(t *testing.T) {
+	tests := []struct {
+		prev, next, want string
+	}{
+		{"", "Hello.", ""},
+		{"Hello.", "How are you?", " "},
+		{"Hello. ", "How are you?", ""},
+		{"你好。", "我很好。", ""},
+		{"Hello.", "你好。", ""},
+	}
+	for _, tt := range tests {
+		if got := joinSeparator(tt.prev, tt.next); got != tt.want {
+			t.Errorf("joinSeparator(%q, %q) = %q, want %q", tt.prev, tt.next, got, tt.want)
+		}
+	}
+}
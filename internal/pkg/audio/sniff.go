@@ -0,0 +1,134 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNotAudio 文件头不是任何已知的音频格式
+	ErrNotAudio = errors.New("audio: 不是音频文件")
+	// ErrUnsupported 是音频，但不是识别服务接受的格式
+	ErrUnsupported = errors.New("audio: 不支持的音频格式")
+)
+
+// 识别服务要求的音频参数
+const (
+	SampleRate = 16000
+	Channels   = 1
+)
+
+// HeaderSize 判断格式和解析 WAV 头需要读取的字节数
+// WAV 的 data 块之前可能还有 LIST 等块，读多一些
+const HeaderSize = 4096
+
+// Sniff 根据文件头的魔数判断音频格式，返回格式名（wav / mp3 / ogg / webm / m4a / flac / amr），无法识别时返回空字符串
+func Sniff(head []byte) string {
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")):
+		return "wav"
+	case bytes.HasPrefix(head, []byte("ID3")),
+		len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return "mp3"
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return "ogg"
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return "webm"
+	case len(head) >= 8 && bytes.Equal(head[4:8], []byte("ftyp")):
+		return "m4a"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return "flac"
+	case bytes.HasPrefix(head, []byte("#!AMR")):
+		return "amr"
+	default:
+		return ""
+	}
+}
+
+// WAVInfo WAV 文件头中的音频参数
+type WAVInfo struct {
+	AudioFormat   uint16 // 1 为 PCM
+	Channels      int
+	SampleRate    int
+	BitsPerSample int
+	ByteRate      int
+	DataOffset    int64 // data 块内容的起始位置
+	DataSize      int64 // data 块的大小，头中没有写明（流式录音）时为 -1
+}
+
+// Duration 按 data 块之后实际的字节数计算音频时长，用于时长上限的校验
+// 识别时整个文件都会发给服务端，头中的 data 大小可以随意填写，所以知道文件大小时不采信头；
+// fileSize <= 0（大小未知）时才按头中的 data 大小计算
+func (w *WAVInfo) Duration(fileSize int64) time.Duration {
+	size := w.DataSize
+	if fileSize > 0 {
+		size = fileSize - w.DataOffset
+	}
+	if size <= 0 || w.ByteRate <= 0 {
+		return 0
+	}
+	return time.Duration(size) * time.Second / time.Duration(w.ByteRate)
+}
+
+// ParseWAV 解析 WAV 文件头，head 至少要包含 fmt 块和 data 块的头部
+func ParseWAV(head []byte) (*WAVInfo, error) {
+	if Sniff(head) != "wav" {
+		return nil, ErrNotAudio
+	}
+	info := &WAVInfo{DataSize: -1}
+	var hasFmt bool
+	for pos := 12; pos+8 <= len(head); {
+		id := string(head[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(head[pos+4 : pos+8]))
+		body := pos + 8
+		switch id {
+		case "fmt ":
+			if size < 16 || body+16 > len(head) {
+				return nil, fmt.Errorf("%w: fmt 块不完整", ErrUnsupported)
+			}
+			info.AudioFormat = binary.LittleEndian.Uint16(head[body:])
+			info.Channels = int(binary.LittleEndian.Uint16(head[body+2:]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(head[body+4:]))
+			info.ByteRate = int(binary.LittleEndian.Uint32(head[body+8:]))
+			info.BitsPerSample = int(binary.LittleEndian.Uint16(head[body+14:]))
+			hasFmt = true
+		case "data":
+			if !hasFmt {
+				return nil, fmt.Errorf("%w: 缺少 fmt 块", ErrUnsupported)
+			}
+			info.DataOffset = int64(body)
+			// 边录边写的文件来不及回填大小，常见写法是 0 或 0xFFFFFFFF
+			if size != 0 && uint32(size) != 0xFFFFFFFF {
+				info.DataSize = int64(size)
+			}
+			return info, nil
+		}
+		// 块按偶数字节对齐
+		pos = body + size + size%2
+	}
+	return nil, fmt.Errorf("%w: 文件头中没有找到 data 块", ErrUnsupported)
+}
+
+// CheckWAV 校验是否为识别服务接受的 16kHz 单声道 16 位 PCM WAV
+// 不是 WAV 的音频返回 ErrUnsupported，不是音频返回 ErrNotAudio
+func CheckWAV(head []byte) (*WAVInfo, error) {
+	switch format := Sniff(head); format {
+	case "wav":
+	case "":
+		return nil, ErrNotAudio
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, format)
+	}
+	info, err := ParseWAV(head)
+	if err != nil {
+		return nil, err
+	}
+	if info.AudioFormat != 1 || info.BitsPerSample != 16 || info.SampleRate != SampleRate || info.Channels != Channels {
+		return nil, fmt.Errorf("%w: 需要 16kHz 单声道 16 位 PCM，实际为 %dHz %d 声道 %d 位（编码 %d）",
+			ErrUnsupported, info.SampleRate, info.Channels, info.BitsPerSample, info.AudioFormat)
+	}
+	return info, nil
+}
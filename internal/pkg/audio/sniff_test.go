@@ -0,0 +1,31 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWAVDuration(t *testing.T) {
+	const offset = 44
+	byteRate := SampleRate * Channels * 2
+	cases := []struct {
+		name     string
+		dataSize int64
+		fileSize int64
+		want     time.Duration
+	}{
+		{"头与文件一致", int64(byteRate), offset + int64(byteRate), time.Second},
+		{"头写小了，按实际字节计算", int64(byteRate), offset + 10*int64(byteRate), 10 * time.Second},
+		{"头写大了（文件被截断）", 10 * int64(byteRate), offset + int64(byteRate), time.Second},
+		{"流式录音没有写大小", -1, offset + 2*int64(byteRate), 2 * time.Second},
+		{"文件大小未知时按头计算", 3 * int64(byteRate), 0, 3 * time.Second},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &WAVInfo{ByteRate: byteRate, DataOffset: offset, DataSize: tc.dataSize}
+			if got := info.Duration(tc.fileSize); got != tc.want {
+				t.Errorf("Duration(%d) = %v，期望 %v", tc.fileSize, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"oktalk/internal/pkg/config"
+)
+
+// 各类音频的 key 前缀，保留规则按前缀设置
+const (
+	RecordingPrefix = "recordings/" // 孩子的录音
+	ReplyPrefix     = "replies/"    // 回复语音
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("blob: 对象不存在")
+
+// Object 对象的元数据
+type Object struct {
+	Key         string
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// BlobStore 对象存储，key 使用 / 分隔的相对路径，如 recordings/42/7/1_user.wav
+type BlobStore interface {
+	// Put 写入对象，size 未知时传 -1
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，调用方负责关闭返回的 ReadCloser
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// SignedURL 生成有效期为 expiry 的下载链接，拿到链接的人无需鉴权即可下载
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// ApplyLifecycle 设置保留时间规则，过期的对象会被自动删除
+	ApplyLifecycle(ctx context.Context, rules []config.LifecycleRule) error
+	// Close 释放存储占用的资源
+	Close() error
+}
+
+// New 按配置创建存储
+func New(conf config.StorageConfig) (BlobStore, error) {
+	switch conf.Backend {
+	case "", "local":
+		return NewLocalStore(conf.Local)
+	case "s3":
+		return NewS3Store(conf.S3)
+	default:
+		return nil, fmt.Errorf("blob: 不支持的存储类型 %s", conf.Backend)
+	}
+}
+
+// AudioContentType 按音频格式（文件扩展名，带不带点都可以）返回 Content-Type
+func AudioContentType(format string) string {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "mp3":
+		return "audio/mpeg"
+	case "wav":
+		return "audio/wav"
+	case "pcm":
+		return "audio/pcm"
+	case "opus", "ogg":
+		return "audio/ogg"
+	case "m4a", "aac":
+		return "audio/mp4"
+	case "webm":
+		return "audio/webm"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,213 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"oktalk/internal/pkg/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 元数据保存在对象旁边的 <文件名>.meta 中
+const metaSuffix = ".meta"
+
+var (
+	// ErrInvalidKey key 为空或试图访问根目录之外的路径
+	ErrInvalidKey = errors.New("blob: 非法的 key")
+	// ErrBadSignature 下载链接签名错误或已过期
+	ErrBadSignature = errors.New("blob: 链接签名无效或已过期")
+)
+
+type localMeta struct {
+	ContentType string `json:"content_type"`
+}
+
+// LocalStore 本地文件系统存储
+// 签名链接指向服务自己的下载接口（见 Verify），过期文件由 janitor 定期调用 SweepPrefix 清理
+type LocalStore struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+func NewLocalStore(conf config.LocalBlobConfig) (*LocalStore, error) {
+	key := []byte(conf.SigningKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		logrus.Warn("⚠️ 未配置 storage.local.signing_key，使用随机密钥，重启后旧的下载链接会失效")
+	}
+	if err := os.MkdirAll(conf.Root, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &LocalStore{
+		root:       conf.Root,
+		baseURL:    strings.TrimSuffix(conf.BaseURL, "/"),
+		signingKey: key,
+	}, nil
+}
+
+// path key 对应的文件路径，拒绝 .. 等越界路径
+func (s *LocalStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || clean == "/" || strings.HasSuffix(clean, metaSuffix) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，读取方不会看到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	meta, _ := json.Marshal(localMeta{ContentType: contentType})
+	if err := os.WriteFile(path+metaSuffix, meta, 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	obj := &Object{Key: key, Size: info.Size(), ModTime: info.ModTime(), ContentType: "application/octet-stream"}
+	if raw, err := os.ReadFile(path + metaSuffix); err == nil {
+		var meta localMeta
+		if json.Unmarshal(raw, &meta) == nil && meta.ContentType != "" {
+			obj.ContentType = meta.ContentType
+		}
+	}
+	return f, obj, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(path + metaSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL 生成 base_url/<key>?expires=<unix 秒>&sig=<HMAC-SHA256(key|expires)>
+func (s *LocalStore) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	q := url.Values{"expires": {expires}, "sig": {s.sign(key, expires)}}
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+// Verify 校验下载链接的签名和有效期
+func (s *LocalStore) Verify(key, expires, sig string) error {
+	ts, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > ts {
+		return ErrBadSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(key, expires))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func (s *LocalStore) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s|%s", key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ApplyLifecycle 本地存储的过期文件由 janitor 调用 SweepPrefix 清理，这里无需处理
+func (s *LocalStore) ApplyLifecycle(ctx context.Context, rules []config.LifecycleRule) error {
+	return nil
+}
+
+// SweepPrefix 删除 key 以 prefix 开头、创建时间超过 maxAge 的对象
+func (s *LocalStore) SweepPrefix(ctx context.Context, prefix string, maxAge time.Duration) (files int, bytes int64) {
+	deadline := time.Now().Add(-maxAge)
+	dir := filepath.Join(s.root, filepath.FromSlash(filepath.Clean("/"+prefix)))
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil || d.IsDir() || strings.HasSuffix(path, metaSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			return nil
+		}
+		rel, _ := filepath.Rel(s.root, path)
+		if !strings.HasPrefix(filepath.ToSlash(rel), prefix) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			logrus.WithContext(ctx).Warnf("清理过期文件失败 %s: %v", path, err)
+			return nil
+		}
+		os.Remove(path + metaSuffix)
+		files++
+		bytes += info.Size()
+		return nil
+	})
+	return files, bytes
+}
+
+func (s *LocalStore) Close() error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"oktalk/internal/pkg/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// S3Store S3 兼容的对象存储（MinIO、阿里云 OSS 等）
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Store(conf config.S3BlobConfig) (*S3Store, error) {
+	lookup := minio.BucketLookupDNS
+	if conf.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(conf.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(conf.AccessKeyID, conf.SecretAccessKey, ""),
+		Secure:       conf.UseSSL,
+		Region:       conf.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("blob: 创建 S3 客户端失败: %w", err)
+	}
+	return &S3Store{client: client, bucket: conf.Bucket}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, s.convertErr(err)
+	}
+	// GetObject 不会发请求，Stat 时才知道对象是否存在
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, nil, s.convertErr(err)
+	}
+	return obj, &Object{Key: key, ContentType: info.ContentType, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3Store) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// ApplyLifecycle 把规则写入桶的生命周期配置，由存储服务端负责删除过期对象
+// 会覆盖桶上原有的生命周期配置
+func (s *S3Store) ApplyLifecycle(ctx context.Context, rules []config.LifecycleRule) error {
+	conf := lifecycle.NewConfiguration()
+	for _, rule := range rules {
+		if rule.ExpireDays <= 0 {
+			continue
+		}
+		conf.Rules = append(conf.Rules, lifecycle.Rule{
+			ID:         "oktalk-expire-" + rule.Prefix,
+			Status:     "Enabled",
+			RuleFilter: lifecycle.Filter{Prefix: rule.Prefix},
+			Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(rule.ExpireDays)},
+		})
+	}
+	if len(conf.Rules) == 0 {
+		return nil
+	}
+	return s.client.SetBucketLifecycle(ctx, s.bucket, conf)
+}
+
+func (s *S3Store) Close() error {
+	return nil
+}
+
+func (s *S3Store) convertErr(err error) error {
+	if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
@@ -1,68 +1,192 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 
 	"github.com/spf13/viper"
 )
 
 var GlobalConfig *Config
 
-func InitConfig() *Config {
-	v := viper.New()
-	v.SetConfigName("config")    // 文件名
-	v.SetConfigType("yaml")      // 扩展名
-	v.AddConfigPath("./configs") // 路径
+// DefaultConfigFile 没有通过 --config 或环境变量 OKTALK_CONFIG 指定时使用的配置文件
+const DefaultConfigFile = "./configs/config.yaml"
 
-	if err := v.ReadInConfig(); err != nil {
-		panic(fmt.Errorf("fatal error config file: %w", err))
-	}
-
-	if err := v.Unmarshal(&GlobalConfig); err != nil {
-		panic(fmt.Errorf("unmarshal config failed: %w", err))
+// InitConfig 加载并校验配置，失败时列出全部问题后退出
+func InitConfig(path string) *Config {
+	conf, err := Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
 	}
+	GlobalConfig = conf
 
 	fmt.Println("✅ 配置中心初始化成功")
+	// 密钥已替换为 ******
 	fmt.Printf("%+v\n", GlobalConfig)
 	return GlobalConfig
 }
 
+// Load 加载配置，优先级：_FILE 密钥文件 > 环境变量 > 配置文件
+// path 为空时依次使用环境变量 OKTALK_CONFIG 和 DefaultConfigFile；
+// 默认的配置文件不存在时只使用环境变量，明确指定的配置文件不存在则返回错误
+func Load(path string) (*Config, error) {
+	explicit := true
+	if path == "" {
+		path = os.Getenv(EnvPrefix + "_CONFIG")
+	}
+	if path == "" {
+		path, explicit = DefaultConfigFile, false
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+		}
+		fmt.Printf("⚠️ 配置文件 %s 不存在，只使用环境变量\n", path)
+	}
+
+	// 环境变量和密钥文件的问题与校验结果一起列出
+	var p problems
+	var ve *ValidationError
+	if err := bindEnv(v); errors.As(err, &ve) {
+		p = append(p, ve.Problems...)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var conf Config
+	if err := v.Unmarshal(&conf); err != nil {
+		return nil, fmt.Errorf("解析配置失败: %w", err)
+	}
+	if err := conf.Validate(); errors.As(err, &ve) {
+		p = append(p, ve.Problems...)
+	}
+	if len(p) > 0 {
+		return nil, &ValidationError{Problems: p}
+	}
+	return &conf, nil
+}
+
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Aliyun   AliyunConfig   `mapstructure:"aliyun"`
-	Xfyun    XfyunConfig    `mapstructure:"xfyun"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Aliyun     AliyunConfig     `mapstructure:"aliyun"`
+	Xfyun      XfyunConfig      `mapstructure:"xfyun"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	Resilience ResilienceConfig `mapstructure:"resilience"`
+	Pricing    PricingConfig    `mapstructure:"pricing"`
+	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	Quota      QuotaConfig      `mapstructure:"quota"`
+	Parental   ParentalConfig   `mapstructure:"parental"`
+	Upload     UploadConfig     `mapstructure:"upload"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	Health     HealthConfig     `mapstructure:"health"`
+	Retention  RetentionConfig  `mapstructure:"retention"`
+	Trace      TraceConfig      `mapstructure:"trace"`
 }
 
 type ServerConfig struct {
 	Port       int    `mapstructure:"port"`
 	Mode       string `mapstructure:"mode"`
 	ServerName string `mapstructure:"server_name"`
+	AdminToken string `mapstructure:"admin_token" secret:"true"` // 管理接口的访问令牌，为空时管理接口不可用
+
+	ReadTimeoutSeconds  int `mapstructure:"read_timeout_seconds"`  // 读取整个请求（含上传的音频）的超时
+	WriteTimeoutSeconds int `mapstructure:"write_timeout_seconds"` // 写响应的超时，需要覆盖一轮完整的流式回复
+	IdleTimeoutSeconds  int `mapstructure:"idle_timeout_seconds"`  // keep-alive 连接的空闲超时
+	// DrainDelaySeconds 收到退出信号后先标记为未就绪，等负载均衡摘除流量后再停止接收新连接
+	DrainDelaySeconds int `mapstructure:"drain_delay_seconds"`
+	// ShutdownTimeoutSeconds 等待进行中的请求（包括 SSE 流式回复）结束的最长时间，超时后强制断开
+	ShutdownTimeoutSeconds int `mapstructure:"shutdown_timeout_seconds"`
+	// TaskTimeoutSeconds 请求结束后再等待后台任务（保存对话记录等）完成的最长时间，超时后直接释放资源
+	TaskTimeoutSeconds int `mapstructure:"task_timeout_seconds"`
 }
 
 type AliyunConfig struct {
-	DASHSCOPE_API_KEY string          `mapstructure:"DASHSCOPE_API_KEY"`
+	DASHSCOPE_API_KEY string          `mapstructure:"DASHSCOPE_API_KEY" secret:"true"`
 	LLM               AliyunLLMConfig `mapstructure:"LLM"`
 	ASR               AliyunASRConfig `mapstructure:"ASR"`
 	TTS               AliyunTTSConfig `mapstructure:"TTS"`
+	WsPool            WsPoolConfig    `mapstructure:"ws_pool"`
+}
+
+// WsPoolConfig ASR / TTS 共用的 DashScope WebSocket 连接池
+type WsPoolConfig struct {
+	MaxConns           int `mapstructure:"max_conns"`            // 最大连接数（使用中 + 空闲），0 表示不限制
+	MaxIdle            int `mapstructure:"max_idle"`             // 每个地址最多保留的空闲连接数，0 表示不限制
+	IdleTimeoutSeconds int `mapstructure:"idle_timeout_seconds"` // 空闲超过该时间的连接会被关闭，0 表示不清理
+	DialTimeoutSeconds int `mapstructure:"dial_timeout_seconds"` // 建立连接（含 TLS 握手）的超时时间，0 表示默认 10 秒
 }
 type AliyunLLMConfig struct {
-	BaseURL string `mapstructure:"base_url"`
-	Model   string `mapstructure:"model"`
+	BaseURL string           `mapstructure:"base_url"`
+	Model   string           `mapstructure:"model"`
+	Models  []LLMModelConfig `mapstructure:"models"` // 按顺序尝试的模型链，为空时只使用 model
+}
+
+// LLMModelConfig 模型链中的一个模型
+type LLMModelConfig struct {
+	Model           string `mapstructure:"model"`
+	BaseURL         string `mapstructure:"base_url"`          // 为空时使用 LLM.base_url
+	LatencyBudgetMs int    `mapstructure:"latency_budget_ms"` // 超过该时间未回复就切换到下一个模型，0 表示不限制
+}
+
+// ModelChain 返回模型链，兼容只配置了单个 model 的旧配置
+func (c AliyunLLMConfig) ModelChain() []LLMModelConfig {
+	if len(c.Models) > 0 {
+		return c.Models
+	}
+	return []LLMModelConfig{{Model: c.Model}}
 }
+
 type AliyunASRConfig struct {
-	WsURL string `mapstructure:"ws_url"`
-	Model string `mapstructure:"model"`
+	WsURL     string `mapstructure:"ws_url"`
+	Model     string `mapstructure:"model"`
+	ChunkSize int    `mapstructure:"chunk_size"` // 每帧发送的字节数，默认 3200（100ms）
+	Pacing    string `mapstructure:"pacing"`     // 录音文件的发送节奏：none 不限速 / realtime 按音频时长匀速
+
+	StartTimeoutSeconds  int `mapstructure:"start_timeout_seconds"`  // 等待 task-started 的超时，默认 10 秒
+	ResultTimeoutSeconds int `mapstructure:"result_timeout_seconds"` // 发送完音频后等待识别结果的超时，默认 30 秒
 }
 type AliyunTTSConfig struct {
-	WsURL string `mapstructure:"ws_url"`
-	Model string `mapstructure:"model"`
+	WsURL        string                  `mapstructure:"ws_url"`
+	Model        string                  `mapstructure:"model"`
+	DefaultVoice string                  `mapstructure:"default_voice"` // 未指定音色时使用的档案名
+	Voices       map[string]VoiceProfile `mapstructure:"voices"`        // 音色目录：档案名 -> 合成参数
+	Scenarios    map[string]string       `mapstructure:"scenarios"`     // 场景名 -> 音色档案名
+	Cache        TTSCacheConfig          `mapstructure:"cache"`
+
+	StartTimeoutSeconds      int `mapstructure:"start_timeout_seconds"`      // 等待 task-started 的超时，默认 10 秒
+	SynthesizeTimeoutSeconds int `mapstructure:"synthesize_timeout_seconds"` // 非流式合成整体的超时，默认 40 秒
+}
+
+// TTSCacheConfig 合成结果缓存
+type TTSCacheConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	TTLSeconds    int    `mapstructure:"ttl_seconds"`     // 缓存有效期
+	MaxRedisBytes int    `mapstructure:"max_redis_bytes"` // 不超过该大小的音频直接存 Redis
+	MaxItemBytes  int    `mapstructure:"max_item_bytes"`  // 超过该大小的音频不缓存，0 表示不限制
+	LocalDir      string `mapstructure:"local_dir"`       // 大音频的本地存放目录，为空时大音频不缓存
+}
+
+// VoiceProfile 一套可复用的合成参数（音色、语速、格式等）
+type VoiceProfile struct {
+	Voice      string  `mapstructure:"voice"`
+	Format     string  `mapstructure:"format"`
+	SampleRate int     `mapstructure:"sample_rate"`
+	Volume     int     `mapstructure:"volume"` // 1-100，0 表示默认 50
+	Rate       float64 `mapstructure:"rate"`
+	Pitch      float64 `mapstructure:"pitch"`
 }
 type XfyunConfig struct {
+	WsURL     string `mapstructure:"ws_url"` // 语音评测接口地址
 	AppId     string `mapstructure:"app_id"`
-	ApiSecret string `mapstructure:"api_secret"`
-	ApiKey    string `mapstructure:"api_key"`
+	ApiSecret string `mapstructure:"api_secret" secret:"true"`
+	ApiKey    string `mapstructure:"api_key" secret:"true"`
 }
 
 type DatabaseConfig struct {
@@ -70,7 +194,7 @@ type DatabaseConfig struct {
 	Host            string `mapstructure:"host"`
 	Port            string `mapstructure:"port"`
 	User            string `mapstructure:"user"`
-	Password        string `mapstructure:"password"`
+	Password        string `mapstructure:"password" secret:"true"`
 	Database        string `mapstructure:"database"`
 	MaxIdleConns    int    `mapstructure:"max_idle_conns"`
 	MaxOpenConns    int    `mapstructure:"max_open_conns"`
@@ -80,6 +204,182 @@ type DatabaseConfig struct {
 type RedisConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     string `mapstructure:"port"`
-	Password string `mapstructure:"password"`
+	Password string `mapstructure:"password" secret:"true"`
 	DB       int    `mapstructure:"db"`
 }
+
+// ResilienceConfig 各 AI 服务的超时、重试、熔断策略
+type ResilienceConfig struct {
+	ASR ProviderPolicy `mapstructure:"asr"`
+	LLM ProviderPolicy `mapstructure:"llm"`
+	TTS ProviderPolicy `mapstructure:"tts"`
+}
+
+type ProviderPolicy struct {
+	TimeoutSeconds int           `mapstructure:"timeout_seconds"` // 单次调用超时，0 表示不限制
+	MaxRetries     int           `mapstructure:"max_retries"`     // 失败后最多重试次数
+	BackoffBaseMs  int           `mapstructure:"backoff_base_ms"` // 第一次重试前的等待时间，之后指数增长
+	BackoffMaxMs   int           `mapstructure:"backoff_max_ms"`  // 重试等待时间上限
+	Breaker        BreakerConfig `mapstructure:"breaker"`
+}
+
+type BreakerConfig struct {
+	FailureThreshold int `mapstructure:"failure_threshold"` // 连续失败多少次后熔断，0 表示不熔断
+	OpenSeconds      int `mapstructure:"open_seconds"`      // 熔断持续时间，之后放行一个试探请求
+}
+
+// PricingConfig 各模型的单价，用于计算用量成本
+type PricingConfig struct {
+	Currency string       `mapstructure:"currency"`
+	Models   []ModelPrice `mapstructure:"models"` // 模型名可能包含 "."，用列表而不是 map 配置
+}
+
+// ModelPrice 一个模型的单价，只需填写该模型适用的计费项
+type ModelPrice struct {
+	Model             string  `mapstructure:"model"`
+	InputPer1KTokens  float64 `mapstructure:"input_per_1k_tokens"`  // LLM 输入，每千 token
+	OutputPer1KTokens float64 `mapstructure:"output_per_1k_tokens"` // LLM 输出，每千 token
+	PerAudioSecond    float64 `mapstructure:"per_audio_second"`     // ASR，每秒音频
+	Per10KCharacters  float64 `mapstructure:"per_10k_characters"`   // TTS，每万字符
+}
+
+// Price 查找模型的单价，未配置时返回 false
+func (c PricingConfig) Price(model string) (ModelPrice, bool) {
+	for _, p := range c.Models {
+		if p.Model == model {
+			return p, true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// RateLimitConfig 对话接口的限流，用户和 IP 各一个令牌桶
+type RateLimitConfig struct {
+	Enabled bool     `mapstructure:"enabled"`
+	User    RateRule `mapstructure:"user"` // 按 X-User-ID 限流，匿名请求不适用
+	IP      RateRule `mapstructure:"ip"`   // 按客户端 IP 限流，X-User-ID 可以伪造，开启限流时必须配置
+}
+
+// RateRule 令牌桶参数：每分钟补充 per_minute 个令牌，最多积攒 burst 个
+type RateRule struct {
+	PerMinute int `mapstructure:"per_minute"` // 0 表示不限制
+	Burst     int `mapstructure:"burst"`
+}
+
+// QuotaConfig 按套餐划分的每日额度
+type QuotaConfig struct {
+	Enabled     bool                 `mapstructure:"enabled"`
+	DefaultPlan string               `mapstructure:"default_plan"` // 没有开通套餐的用户（包括匿名用户）使用的套餐
+	Plans       map[string]PlanQuota `mapstructure:"plans"`
+	// IP 同一个客户端 IP 下所有用户合计的每日额度，学校、家庭共用出口 IP，要远高于单个套餐的额度
+	IP PlanQuota `mapstructure:"ip"`
+}
+
+// PlanQuota 一个套餐每天的额度，0 表示不限制
+type PlanQuota struct {
+	DailyVoiceMinutes int `mapstructure:"daily_voice_minutes"` // 孩子说话的音频时长（分钟）
+	DailyTurns        int `mapstructure:"daily_turns"`         // 对话轮数
+}
+
+// 没有 X-User-ID 的请求的处理方式
+const (
+	AnonymousDeny    = "deny"    // 直接拒绝
+	AnonymousDefault = "default" // 按 parental.default 的允许时段限制，无法统计时长
+	AnonymousAllow   = "allow"   // 不限制
+)
+
+// ParentalConfig 家长控制的全局设置
+// 家长控制按 X-User-ID 生效，而 X-User-ID 由客户端自报，限制只和这个 ID 一样可靠
+type ParentalConfig struct {
+	Anonymous string           `mapstructure:"anonymous"` // deny / default / allow，为空时按 deny 处理
+	Timezone  string           `mapstructure:"timezone"`  // 判断允许时段和“今天”的时区（IANA 名称），孩子单独设置了时区时以孩子的为准，为空时使用服务器本地时区
+	Default   ParentalDefaults `mapstructure:"default"`   // 孩子没有设置过家长控制、以及匿名请求使用的限制
+}
+
+// ParentalDefaults 默认的家长控制，含义与 model.ParentalControl 相同，零值表示不限制
+type ParentalDefaults struct {
+	MaxDailyMinutes   int    `mapstructure:"max_daily_minutes"`
+	AllowedFrom       string `mapstructure:"allowed_from"`
+	AllowedTo         string `mapstructure:"allowed_to"`
+	BreakEveryMinutes int    `mapstructure:"break_every_minutes"`
+}
+
+// HealthConfig /readyz 的依赖检查
+type HealthConfig struct {
+	CacheTTLSeconds int `mapstructure:"cache_ttl_seconds"` // 检查结果的缓存时间
+	TimeoutSeconds  int `mapstructure:"timeout_seconds"`   // 单个依赖的检查超时
+	// CheckDashScope / CheckXfyun 是否检查外部 AI 服务的连通性和鉴权
+	// 外部服务不可用时所有实例都一样，只在结果中展示，不影响就绪状态
+	CheckDashScope bool `mapstructure:"check_dashscope"`
+	CheckXfyun     bool `mapstructure:"check_xfyun"`
+}
+
+// TraceConfig OpenTelemetry trace 的导出、采样和资源属性
+type TraceConfig struct {
+	// Exporter otlp_http / otlp_grpc / stdout / none
+	// none 不导出，但仍然生成 TraceID 用于日志和用量记录；为空时为 none
+	Exporter       string            `mapstructure:"exporter"`
+	Endpoint       string            `mapstructure:"endpoint"`              // OTLP 接收端地址 host:port
+	URLPath        string            `mapstructure:"url_path"`              // 仅 otlp_http，为空时为 /v1/traces
+	Insecure       bool              `mapstructure:"insecure"`              // 不使用 TLS
+	Headers        map[string]string `mapstructure:"headers" secret:"true"` // 导出请求附带的头，如鉴权 token
+	TimeoutSeconds int               `mapstructure:"timeout_seconds"`       // 单次导出的超时
+
+	// Sampler always_on / always_off / traceidratio，以及带 parentbased_ 前缀的同名采样器（跟随上游的采样决定）
+	// 为空时为 parentbased_always_on
+	Sampler     string  `mapstructure:"sampler"`
+	SampleRatio float64 `mapstructure:"sample_ratio"` // traceidratio 的采样比例 0~1
+
+	ServiceName        string            `mapstructure:"service_name"` // 为空时为 oktalk
+	ServiceVersion     string            `mapstructure:"service_version"`
+	Environment        string            `mapstructure:"environment"`         // 部署环境，如 dev / prod
+	ResourceAttributes map[string]string `mapstructure:"resource_attributes"` // 额外的资源属性
+}
+
+// UploadConfig 语音上传的限制，0 表示不限制
+type UploadConfig struct {
+	MaxBodyBytes       int64 `mapstructure:"max_body_bytes"`       // 请求体大小上限
+	MaxDurationSeconds int   `mapstructure:"max_duration_seconds"` // 音频时长上限
+	MemoryLimitBytes   int64 `mapstructure:"memory_limit_bytes"`   // 不超过该大小的音频在内存中直接送去识别，不写临时文件；0 表示都写临时文件
+}
+
+// StorageConfig 音频文件存储（孩子的录音、回复语音）
+type StorageConfig struct {
+	Backend             string          `mapstructure:"backend"`                // local / s3
+	TempDir             string          `mapstructure:"temp_dir"`               // 上传音频在识别期间的临时目录
+	SignedURLTTLSeconds int             `mapstructure:"signed_url_ttl_seconds"` // 下载链接的有效期
+	Local               LocalBlobConfig `mapstructure:"local"`
+	S3                  S3BlobConfig    `mapstructure:"s3"`
+}
+
+// LocalBlobConfig 本地文件系统存储
+type LocalBlobConfig struct {
+	Root       string `mapstructure:"root"`                      // 存储根目录
+	BaseURL    string `mapstructure:"base_url"`                  // 下载接口的地址，签名链接为 base_url/<key>?expires=..&sig=..
+	SigningKey string `mapstructure:"signing_key" secret:"true"` // 下载链接的签名密钥，为空时启动时随机生成（重启后旧链接失效）
+}
+
+// S3BlobConfig S3 兼容的对象存储（MinIO、阿里云 OSS 等）
+type S3BlobConfig struct {
+	Endpoint        string `mapstructure:"endpoint"` // 不带协议，如 oss-cn-hangzhou.aliyuncs.com
+	Region          string `mapstructure:"region"`
+	Bucket          string `mapstructure:"bucket"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key" secret:"true"`
+	UseSSL          bool   `mapstructure:"use_ssl"`
+	PathStyle       bool   `mapstructure:"path_style"` // MinIO 使用路径风格，OSS 使用虚拟主机风格
+}
+
+// RetentionConfig 音频文件按类别的保留时间，0 表示不清理
+type RetentionConfig struct {
+	SweepIntervalMinutes int `mapstructure:"sweep_interval_minutes"` // 后台清理的间隔
+	TempUploadMinutes    int `mapstructure:"temp_upload_minutes"`    // 上传的临时文件，正常情况下请求结束就删除，这里清理异常退出遗留的
+	RecordingDays        int `mapstructure:"recording_days"`         // 孩子的录音
+	TTSOutputDays        int `mapstructure:"tts_output_days"`        // 保存的回复语音（TTS 缓存文件按 cache.ttl_seconds 清理）
+}
+
+// LifecycleRule 对象的保留时间：key 以 prefix 开头的对象创建 expire_days 天后删除
+type LifecycleRule struct {
+	Prefix     string `mapstructure:"prefix"`
+	ExpireDays int    `mapstructure:"expire_days"`
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix 环境变量前缀，配置项 aliyun.DASHSCOPE_API_KEY 对应 OKTALK_ALIYUN_DASHSCOPE_API_KEY
+const EnvPrefix = "OKTALK"
+
+// fileSuffix 以 _FILE 结尾的环境变量表示从文件读取配置值（如挂载的 Kubernetes / Docker secret）
+const fileSuffix = "_FILE"
+
+// EnvName 配置项对应的环境变量名
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// configKeys 列出 Config 中所有可以用环境变量覆盖的配置项
+// map 和列表（音色目录、模型链等）结构不固定，只能在配置文件中配置
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			keys = append(keys, configKeys(field.Type, key)...)
+		case reflect.Map, reflect.Slice:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// bindEnv 绑定每个配置项的环境变量，并读取 _FILE 形式的密钥文件
+// 环境变量优先于配置文件，配置文件中没有写的配置项也可以通过环境变量设置
+func bindEnv(v *viper.Viper) error {
+	var problems []string
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		env := EnvName(key)
+		if err := v.BindEnv(key, env); err != nil {
+			return err
+		}
+		path, ok := os.LookupEnv(env + fileSuffix)
+		if !ok {
+			continue
+		}
+		if _, ok := os.LookupEnv(env); ok {
+			problems = append(problems, fmt.Sprintf("%s 和 %s 不能同时设置", env, env+fileSuffix))
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: 读取密钥文件失败: %v", env+fileSuffix, err))
+			continue
+		}
+		// 文件末尾的换行不属于密钥
+		v.Set(key, strings.TrimRight(string(data), "\r\n"))
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
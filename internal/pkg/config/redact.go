@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// redactedValue 日志中代替密钥的内容
+const redactedValue = "******"
+
+// Redacted 返回把密钥替换为 ****** 的副本，用于打印配置
+// 密钥字段用 `secret:"true"` 标记，map 类型的字段（如鉴权头）替换全部取值
+func (c *Config) Redacted() *Config {
+	out := *c
+	redact(reflect.ValueOf(&out).Elem())
+	return &out
+}
+
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			redact(value)
+			continue
+		}
+		if field.Tag.Get("secret") != "true" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.String:
+			if value.Len() > 0 {
+				value.SetString(redactedValue)
+			}
+		case reflect.Map:
+			// 副本和原配置共用同一个 map，换成新的 map 再替换
+			if value.Len() == 0 {
+				continue
+			}
+			masked := reflect.MakeMapWithSize(field.Type, value.Len())
+			for _, k := range value.MapKeys() {
+				masked.SetMapIndex(k, reflect.ValueOf(redactedValue))
+			}
+			value.Set(masked)
+		}
+	}
+}
+
+// String 打印配置时隐藏密钥，避免 %v / %+v 把密钥写进日志
+func (c Config) String() string {
+	type plain Config
+	return fmt.Sprintf("%+v", plain(*c.Redacted()))
+}
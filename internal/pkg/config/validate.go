@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ValidationError 启动时发现的全部配置问题，一次列出，避免改一项启动一次
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "配置校验失败：\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// problems 收集校验问题
+type problems []string
+
+func (p *problems) add(format string, args ...any) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// require 必填项为空时提示对应的环境变量
+func (p *problems) require(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		p.add("%s 未设置（环境变量 %s）", key, EnvName(key))
+	}
+}
+
+// oneOf 取值必须是 allowed 之一
+func (p *problems) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	p.add("%s 的取值 %q 无效，可选值：%s", key, value, strings.Join(allowed, " / "))
+}
+
+// nonNegative 数值不能为负数
+func (p *problems) nonNegative(key string, value int64) {
+	if value < 0 {
+		p.add("%s 不能为负数: %d", key, value)
+	}
+}
+
+// clock 不为空时必须是 HH:MM
+func (p *problems) clock(key, value string) {
+	if _, err := time.Parse("15:04", value); value != "" && err != nil {
+		p.add("%s 必须是 HH:MM: %q", key, value)
+	}
+}
+
+// Validate 校验启动必需的配置，返回包含全部问题的 *ValidationError
+// trace 导出等非关键配置出错时在初始化时降级，不在这里校验
+func (c *Config) Validate() error {
+	var p problems
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		p.add("server.port 无效: %d", c.Server.Port)
+	}
+	p.oneOf("server.mode", c.Server.Mode, "", "debug", "release", "test")
+
+	p.require("aliyun.DASHSCOPE_API_KEY", c.Aliyun.DASHSCOPE_API_KEY)
+	if len(c.Aliyun.LLM.Models) == 0 {
+		p.require("aliyun.LLM.model", c.Aliyun.LLM.Model)
+	}
+	for i, m := range c.Aliyun.LLM.Models {
+		if m.Model == "" {
+			p.add("aliyun.LLM.models[%d].model 未设置", i)
+		}
+	}
+	for _, m := range c.Aliyun.LLM.ModelChain() {
+		if m.BaseURL == "" && c.Aliyun.LLM.BaseURL == "" {
+			p.add("aliyun.LLM.base_url 未设置，模型 %s 没有单独配置 base_url", m.Model)
+		}
+	}
+	p.require("aliyun.ASR.ws_url", c.Aliyun.ASR.WsURL)
+	p.require("aliyun.ASR.model", c.Aliyun.ASR.Model)
+	p.oneOf("aliyun.ASR.pacing", c.Aliyun.ASR.Pacing, "", "none", "realtime")
+	p.require("aliyun.TTS.ws_url", c.Aliyun.TTS.WsURL)
+	p.require("aliyun.TTS.model", c.Aliyun.TTS.Model)
+
+	if c.Health.CheckXfyun {
+		p.require("xfyun.app_id", c.Xfyun.AppId)
+		p.require("xfyun.api_key", c.Xfyun.ApiKey)
+		p.require("xfyun.api_secret", c.Xfyun.ApiSecret)
+	}
+
+	p.require("database.host", c.Database.Host)
+	p.require("database.port", c.Database.Port)
+	p.require("database.user", c.Database.User)
+	p.require("database.database", c.Database.Database)
+	p.require("redis.host", c.Redis.Host)
+	p.require("redis.port", c.Redis.Port)
+
+	p.oneOf("storage.backend", c.Storage.Backend, "", "local", "s3")
+	if c.Storage.Backend == "s3" {
+		p.require("storage.s3.endpoint", c.Storage.S3.Endpoint)
+		p.require("storage.s3.bucket", c.Storage.S3.Bucket)
+		p.require("storage.s3.access_key_id", c.Storage.S3.AccessKeyID)
+		p.require("storage.s3.secret_access_key", c.Storage.S3.SecretAccessKey)
+	}
+
+	if c.RateLimit.Enabled && c.RateLimit.IP.PerMinute <= 0 {
+		p.add("rate_limit.ip.per_minute 必须大于 0：X-User-ID 由客户端自报，只按用户限流可以被绕过")
+	}
+
+	p.oneOf("parental.anonymous", c.Parental.Anonymous, "", AnonymousDeny, AnonymousDefault, AnonymousAllow)
+	if _, err := time.LoadLocation(c.Parental.Timezone); err != nil {
+		p.add("parental.timezone 无效: %v", err)
+	}
+	p.clock("parental.default.allowed_from", c.Parental.Default.AllowedFrom)
+	p.clock("parental.default.allowed_to", c.Parental.Default.AllowedTo)
+
+	p.nonNegative("upload.max_body_bytes", c.Upload.MaxBodyBytes)
+	p.nonNegative("upload.max_duration_seconds", int64(c.Upload.MaxDurationSeconds))
+	p.nonNegative("upload.memory_limit_bytes", c.Upload.MemoryLimitBytes)
+
+	if len(p) > 0 {
+		return &ValidationError{Problems: p}
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+package constants
+
+const UserIDKey string = "user_id"
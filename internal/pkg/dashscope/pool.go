@@ -0,0 +1,312 @@
+package dashscope
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"oktalk/internal/pkg/config"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrPoolClosed 连接池已关闭
+var ErrPoolClosed = errors.New("dashscope: 连接池已关闭")
+
+// Endpoint 一类连接的地址和握手头，地址和头都相同的连接才能互相复用
+type Endpoint struct {
+	URL    string
+	Header http.Header
+}
+
+// NewEndpoint DashScope 推理服务的 WebSocket 地址和鉴权头
+// ASR 和 TTS 都通过它构造 Endpoint，地址和头完全一致，连接才能在两者之间复用
+func NewEndpoint(url, apiKey string) Endpoint {
+	header := make(http.Header)
+	header.Set("Authorization", "bearer "+apiKey)
+	header.Set("X-DashScope-DataInspection", "enable")
+	return Endpoint{URL: strings.TrimRight(url, "/"), Header: header}
+}
+
+func (e Endpoint) key() string {
+	keys := make([]string, 0, len(e.Header))
+	for k := range e.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(e.URL)
+	for _, k := range keys {
+		sb.WriteString("|" + k + "=" + strings.Join(e.Header[k], ","))
+	}
+	return sb.String()
+}
+
+// Pool DashScope WebSocket 连接池，ASR 和 TTS 共用
+// 同一条连接上可以顺序执行多个任务（run-task ... task-finished），复用连接可以省掉每轮对话的 TLS 握手
+type Pool struct {
+	conf   config.WsPoolConfig
+	dialer *websocket.Dialer
+
+	mu     sync.Mutex
+	idle   map[string][]*Conn
+	sem    chan struct{} // 限制连接总数（使用中 + 空闲），为 nil 时不限制
+	open   atomic.Int64  // 当前连接总数（使用中 + 空闲），不限制连接数时也要统计
+	closed bool
+	stop   chan struct{}
+}
+
+// Conn 从连接池借出的连接
+// 任务正常结束后调用 Release 归还；任务失败、超时或被取消时调用 Discard 关闭
+type Conn struct {
+	*websocket.Conn
+	pool     *Pool
+	key      string
+	lastUsed time.Time
+	reused   bool
+	returned atomic.Bool // 已经 Release 或 Discard；先置位再归还，重新借出时清零
+}
+
+func NewPool(conf config.WsPoolConfig) *Pool {
+	p := &Pool{
+		conf: conf,
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: dialTimeout(conf.DialTimeoutSeconds),
+		},
+		idle: make(map[string][]*Conn),
+		stop: make(chan struct{}),
+	}
+	if conf.MaxConns > 0 {
+		p.sem = make(chan struct{}, conf.MaxConns)
+	}
+	if conf.IdleTimeoutSeconds > 0 {
+		go p.evictLoop()
+	}
+	return p
+}
+
+// defaultDialTimeout 没有配置 dial_timeout_seconds 时建立连接的超时
+const defaultDialTimeout = 10 * time.Second
+
+func dialTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return defaultDialTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Get 借出一条连接：优先复用健康的空闲连接，没有时新建
+// 达到最大连接数时先关闭其他地址的空闲连接腾出名额，仍然没有名额就阻塞等待，直到有连接归还或 ctx 结束。
+// 新建连接失败时返回握手响应，调用方可以据此区分鉴权失败、限流等情况
+func (p *Pool) Get(ctx context.Context, ep Endpoint) (*Conn, *http.Response, error) {
+	key := ep.key()
+	for {
+		conn, err := p.popIdle(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if conn == nil {
+			break
+		}
+		if p.healthy(conn) {
+			conn.reused = true
+			conn.returned.Store(false)
+			return conn, nil, nil
+		}
+		logrus.WithContext(ctx).Debugf("丢弃不健康的 DashScope 空闲连接")
+		conn.Conn.Close()
+		p.releaseSlot()
+	}
+
+	if err := p.acquireSlot(ctx); err != nil {
+		return nil, nil, err
+	}
+	ws, resp, err := p.dialer.DialContext(ctx, ep.URL, ep.Header)
+	if err != nil {
+		p.releaseSlot()
+		return nil, resp, err
+	}
+	return &Conn{Conn: ws, pool: p, key: key}, resp, nil
+}
+
+// Reused 连接是否是从空闲连接中复用的
+// 复用的连接可能已被服务端关闭，调用方在第一次读写失败时可以换一条新连接重试
+func (c *Conn) Reused() bool {
+	return c.reused
+}
+
+// Release 任务正常结束，把连接放回空闲队列
+func (c *Conn) Release() {
+	if c.returned.CompareAndSwap(false, true) {
+		c.pool.put(c)
+	}
+}
+
+// Discard 关闭连接，不再复用
+func (c *Conn) Discard() {
+	if c.returned.CompareAndSwap(false, true) {
+		c.Conn.Close()
+		c.pool.releaseSlot()
+	}
+}
+
+// Close 关闭连接池和所有空闲连接，借出的连接归还时会被直接关闭
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	for key, conns := range p.idle {
+		for _, conn := range conns {
+			conn.Conn.Close()
+			p.releaseSlot()
+		}
+		delete(p.idle, key)
+	}
+}
+
+// Stats 当前空闲连接数和总连接数
+func (p *Pool) Stats() (idle, open int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conns := range p.idle {
+		idle += len(conns)
+	}
+	return idle, int(p.open.Load())
+}
+
+// popIdle 取出一条空闲连接，取出的连接仍占用它原来的名额
+func (p *Pool) popIdle(key string) (*Conn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	conns := p.idle[key]
+	if len(conns) == 0 {
+		return nil, nil
+	}
+	// 后进先出，最近用过的连接最可能还活着
+	conn := conns[len(conns)-1]
+	p.idle[key] = conns[:len(conns)-1]
+	return conn, nil
+}
+
+// put 归还连接，空闲连接继续占用名额
+func (p *Pool) put(conn *Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || (p.conf.MaxIdle > 0 && len(p.idle[conn.key]) >= p.conf.MaxIdle) {
+		conn.Conn.Close()
+		p.releaseSlot()
+		return
+	}
+	conn.lastUsed = time.Now()
+	p.idle[conn.key] = append(p.idle[conn.key], conn)
+}
+
+// healthy 空闲时间没有超限，且能写出一个 ping 帧
+func (p *Pool) healthy(conn *Conn) bool {
+	if p.conf.IdleTimeoutSeconds > 0 && time.Since(conn.lastUsed) > time.Duration(p.conf.IdleTimeoutSeconds)*time.Second {
+		return false
+	}
+	return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)) == nil
+}
+
+// evictLoop 定期关闭空闲太久的连接
+func (p *Pool) evictLoop() {
+	timeout := time.Duration(p.conf.IdleTimeoutSeconds) * time.Second
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		p.mu.Lock()
+		for key, conns := range p.idle {
+			alive := conns[:0]
+			for _, conn := range conns {
+				if time.Since(conn.lastUsed) > timeout {
+					conn.Conn.Close()
+					p.releaseSlot()
+					continue
+				}
+				alive = append(alive, conn)
+			}
+			p.idle[key] = alive
+		}
+		p.mu.Unlock()
+	}
+}
+
+// acquireSlot 申请一个连接名额
+func (p *Pool) acquireSlot(ctx context.Context) error {
+	if p.sem == nil {
+		p.open.Add(1)
+		return nil
+	}
+	select {
+	case p.sem <- struct{}{}:
+		p.open.Add(1)
+		return nil
+	default:
+	}
+	// 名额被其他地址的空闲连接占满时，关掉最久没用的一条
+	p.evictOldestIdle()
+	select {
+	case p.sem <- struct{}{}:
+		p.open.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.stop:
+		return ErrPoolClosed
+	}
+}
+
+func (p *Pool) evictOldestIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var (
+		oldestKey string
+		oldestIdx = -1
+		oldest    time.Time
+	)
+	for key, conns := range p.idle {
+		for i, conn := range conns {
+			if oldestIdx < 0 || conn.lastUsed.Before(oldest) {
+				oldestKey, oldestIdx, oldest = key, i, conn.lastUsed
+			}
+		}
+	}
+	if oldestIdx < 0 {
+		return
+	}
+	conns := p.idle[oldestKey]
+	conns[oldestIdx].Conn.Close()
+	p.idle[oldestKey] = append(conns[:oldestIdx], conns[oldestIdx+1:]...)
+	p.releaseSlot()
+}
+
+func (p *Pool) releaseSlot() {
+	p.open.Add(-1)
+	if p.sem == nil {
+		return
+	}
+	select {
+	case <-p.sem:
+	default:
+	}
+}
@@ -0,0 +1,79 @@
+package dashscope
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"oktalk/internal/pkg/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// newEchoServer 只完成握手、读到连接关闭为止的 WebSocket 服务
+func newEchoServer(t *testing.T) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestNewEndpointSharedKey(t *testing.T) {
+	asr := NewEndpoint("wss://dashscope.aliyuncs.com/api-ws/v1/inference/", "sk")
+	tts := NewEndpoint("wss://dashscope.aliyuncs.com/api-ws/v1/inference", "sk")
+	if asr.key() != tts.key() {
+		t.Errorf("ASR 和 TTS 的连接不能复用: %q != %q", asr.key(), tts.key())
+	}
+	if other := NewEndpoint("wss://dashscope.aliyuncs.com/api-ws/v1/inference", "sk2"); other.key() == tts.key() {
+		t.Error("不同 API key 的连接不应该复用")
+	}
+}
+
+func TestPoolStatsWithoutMaxConns(t *testing.T) {
+	url := newEchoServer(t)
+	pool := NewPool(config.WsPoolConfig{})
+	defer pool.Close()
+	ctx := context.Background()
+
+	check := func(step string, wantIdle, wantOpen int) {
+		t.Helper()
+		if idle, open := pool.Stats(); idle != wantIdle || open != wantOpen {
+			t.Errorf("%s: Stats() = (%d, %d), want (%d, %d)", step, idle, open, wantIdle, wantOpen)
+		}
+	}
+
+	first, _, err := pool.Get(ctx, NewEndpoint(url+"/", "sk"))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	check("借出一条", 0, 1)
+
+	first.Release()
+	check("归还后", 1, 1)
+
+	second, _, err := pool.Get(ctx, NewEndpoint(url, "sk"))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !second.Reused() {
+		t.Error("地址只差结尾的 / 时应该复用空闲连接")
+	}
+	check("复用后", 0, 1)
+
+	second.Discard()
+	check("关闭后", 0, 0)
+}
@@ -0,0 +1,116 @@
+package errcode
+
+import "net/http"
+
+// 业务状态码
+// 三位数的码就是 HTTP 状态码；五位数的码用于区分具体的失败原因，前三位是返回的 HTTP 状态码，后两位是序号
+const (
+	CodeSuccess      = 200
+	CodeParamError   = 400
+	CodeUnauthorized = 401
+	CodeServerError  = 500
+	CodeNotReady     = 503 // 服务正在启动或退出，暂不接收流量
+
+	// 语音上传
+	CodeAudioMissing     = 40001 // 没有上传音频
+	CodeAudioTooLong     = 40002 // 音频时长超过上限
+	CodeUploadTooLarge   = 41301 // 请求体超过大小上限
+	CodeUnsupportedAudio = 41501 // 音频格式不支持（只接受 16kHz 单声道 16 位 PCM WAV）
+	CodeNotAudio         = 41502 // 上传的文件不是音频
+
+	// 资源
+	CodeLinkExpired     = 40301 // 下载链接签名无效或已过期
+	CodeSessionNotFound = 40401 // 会话不存在或不属于当前孩子
+	CodeFileNotFound    = 40402 // 文件不存在或已过期删除
+	CodeChildNotFound   = 40403 // 孩子不存在或未绑定到当前家长
+	CodeParentNotFound  = 40404 // 家长账号不存在
+
+	// 限流与额度
+	CodeTooManyRequests = 42901 // 请求太频繁
+	CodeQuotaExceeded   = 42902 // 今日额度已用完
+	CodeParentalLimit   = 40302 // 家长设置的限制生效（时长上限、禁用时段），data 中带有提示语音
+
+	// ASR 语音识别
+	CodeASRTimeout       = 50401 // 识别超时
+	CodeASRAuthFailed    = 50201 // 识别服务鉴权失败
+	CodeASRQuotaExceeded = 50301 // 识别服务额度不足或被限流
+	CodeASRBadAudio      = 40003 // 音频无法识别（格式错误、损坏）
+	CodeASRTaskFailed    = 50202 // 识别任务失败
+	CodeASRUnavailable   = 50302 // 识别服务熔断中
+	CodeRequestCanceled  = 49900 // 客户端取消了请求
+
+	// LLM 对话生成
+	CodeLLMFailed      = 50203 // 对话生成失败
+	CodeLLMUnavailable = 50303 // 对话服务熔断中
+
+	// TTS 语音合成
+	CodeTTSInterrupted = 50204 // 流式合成中途失败
+	CodeTTSTimeout     = 50402 // 合成超时
+)
+
+// entry 一个业务码对应的 HTTP 状态码和各语言的提示
+type entry struct {
+	status int
+	zh, en string
+}
+
+// statusClientClosed 客户端在响应之前断开（沿用 nginx 的 499）
+const statusClientClosed = 499
+
+var catalog = map[int]entry{
+	CodeSuccess:      {http.StatusOK, "success", "success"},
+	CodeParamError:   {http.StatusBadRequest, "参数错误", "invalid parameter"},
+	CodeUnauthorized: {http.StatusUnauthorized, "无权访问", "unauthorized"},
+	CodeServerError:  {http.StatusInternalServerError, "服务器内部错误", "internal server error"},
+	CodeNotReady:     {http.StatusServiceUnavailable, "服务暂不可用", "service unavailable"},
+
+	CodeAudioMissing:     {http.StatusBadRequest, "未检测到音频文件上传", "no audio file uploaded"},
+	CodeAudioTooLong:     {http.StatusBadRequest, "录音太长了", "recording is too long"},
+	CodeUploadTooLarge:   {http.StatusRequestEntityTooLarge, "上传内容太大", "upload is too large"},
+	CodeUnsupportedAudio: {http.StatusUnsupportedMediaType, "仅支持 16kHz 单声道 16 位 PCM WAV 音频", "only 16 kHz mono 16-bit PCM WAV audio is supported"},
+	CodeNotAudio:         {http.StatusUnsupportedMediaType, "上传的文件不是音频", "uploaded file is not audio"},
+
+	CodeLinkExpired:     {http.StatusForbidden, "下载链接无效或已过期", "download link is invalid or expired"},
+	CodeSessionNotFound: {http.StatusNotFound, "会话不存在", "session not found"},
+	CodeFileNotFound:    {http.StatusNotFound, "文件不存在或已过期删除", "file not found or expired"},
+	CodeChildNotFound:   {http.StatusNotFound, "孩子不存在或未绑定到当前家长", "child not found"},
+	CodeParentNotFound:  {http.StatusNotFound, "家长账号不存在", "parent not found"},
+
+	CodeTooManyRequests: {http.StatusTooManyRequests, "说得太快啦，休息一下再试吧", "you're going too fast, take a short break and try again"},
+	CodeQuotaExceeded:   {http.StatusTooManyRequests, "今天的练习额度用完啦，明天再来吧", "today's practice quota is used up, see you tomorrow"},
+	CodeParentalLimit:   {http.StatusForbidden, "已达到家长设置的使用限制", "parental limit reached"},
+
+	CodeASRTimeout:       {http.StatusGatewayTimeout, "语音识别超时，请稍后再试", "speech recognition timed out, please try again later"},
+	CodeASRAuthFailed:    {http.StatusBadGateway, "语音识别服务暂不可用", "speech recognition is temporarily unavailable"},
+	CodeASRQuotaExceeded: {http.StatusServiceUnavailable, "语音识别服务繁忙，请稍后再试", "speech recognition is busy, please try again later"},
+	CodeASRBadAudio:      {http.StatusBadRequest, "音频无法识别，请重新录音", "audio could not be recognized, please record again"},
+	CodeASRTaskFailed:    {http.StatusBadGateway, "语音识别失败", "speech recognition failed"},
+	CodeASRUnavailable:   {http.StatusServiceUnavailable, "语音识别服务暂不可用，请稍后再试", "speech recognition is temporarily unavailable, please try again later"},
+	CodeRequestCanceled:  {statusClientClosed, "请求已取消", "request canceled"},
+
+	CodeLLMFailed:      {http.StatusBadGateway, "AI 老师暂时走神了，请稍后再试", "the AI teacher got distracted, please try again later"},
+	CodeLLMUnavailable: {http.StatusServiceUnavailable, "AI 老师正在休息，请稍后再试", "the AI teacher is taking a break, please try again later"},
+
+	CodeTTSInterrupted: {http.StatusBadGateway, "语音合成中断", "speech synthesis was interrupted"},
+	CodeTTSTimeout:     {http.StatusGatewayTimeout, "语音合成超时", "speech synthesis timed out"},
+}
+
+// HTTPStatus 业务码对应的 HTTP 状态码，未登记的码按 500 处理
+func HTTPStatus(code int) int {
+	if e, ok := catalog[code]; ok {
+		return e.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Message 业务码在指定语言下的提示，未登记的码返回服务器内部错误的提示
+func Message(code int, lang Lang) string {
+	e, ok := catalog[code]
+	if !ok {
+		e = catalog[CodeServerError]
+	}
+	if lang == LangEN {
+		return e.en
+	}
+	return e.zh
+}
@@ -0,0 +1,38 @@
+package errcode
+
+import "testing"
+
+// 客户端按前三位判断 HTTP 状态，登记的每个码都要满足这个约定
+func TestCodePrefixMatchesHTTPStatus(t *testing.T) {
+	for code, e := range catalog {
+		prefix := code
+		for prefix >= 1000 {
+			prefix /= 10
+		}
+		if prefix != e.status {
+			t.Errorf("业务码 %d 的前三位 %d 与 HTTP 状态码 %d 不一致", code, prefix, e.status)
+		}
+	}
+}
+
+func TestCodesAreUnique(t *testing.T) {
+	// catalog 是 map，重复的常量会让一个码的提示被另一个覆盖，这里按常量逐个核对
+	codes := []int{
+		CodeSuccess, CodeParamError, CodeUnauthorized, CodeServerError, CodeNotReady,
+		CodeAudioMissing, CodeAudioTooLong, CodeUploadTooLarge, CodeUnsupportedAudio, CodeNotAudio,
+		CodeLinkExpired, CodeSessionNotFound, CodeFileNotFound, CodeChildNotFound, CodeParentNotFound,
+		CodeTooManyRequests, CodeQuotaExceeded, CodeParentalLimit,
+		CodeASRTimeout, CodeASRAuthFailed, CodeASRQuotaExceeded, CodeASRBadAudio, CodeASRTaskFailed, CodeASRUnavailable,
+		CodeRequestCanceled, CodeLLMFailed, CodeLLMUnavailable, CodeTTSInterrupted, CodeTTSTimeout,
+	}
+	seen := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		if seen[code] {
+			t.Errorf("业务码 %d 重复", code)
+		}
+		seen[code] = true
+		if _, ok := catalog[code]; !ok {
+			t.Errorf("业务码 %d 没有登记到 catalog", code)
+		}
+	}
+}
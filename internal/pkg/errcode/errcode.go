@@ -0,0 +1,86 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AppError 携带业务码的错误，由 response.Error 转换为统一响应
+// 提示语按业务码从目录中取，Err 只用于日志，不会返回给客户端
+type AppError struct {
+	Code int
+	// Detail 附加在提示后面的说明，如出错的参数名、大小上限，不区分语言
+	Detail string
+	Err    error
+	// RetryAfter 客户端最早可以重试的时间，限流和额度错误时设置
+	RetryAfter time.Duration
+	// Data 需要随错误一起返回给客户端的数据
+	Data any
+}
+
+// New 创建业务码为 code 的错误
+func New(code int) *AppError {
+	return &AppError{Code: code}
+}
+
+// Wrap 创建业务码为 code 的错误，err 为原始错误
+func Wrap(code int, err error) *AppError {
+	return &AppError{Code: code, Err: err}
+}
+
+// WithDetail 设置附加说明
+func (e *AppError) WithDetail(format string, args ...any) *AppError {
+	e.Detail = fmt.Sprintf(format, args...)
+	return e
+}
+
+// WithRetryAfter 设置客户端最早可以重试的时间
+func (e *AppError) WithRetryAfter(d time.Duration) *AppError {
+	e.RetryAfter = d
+	return e
+}
+
+// WithData 设置随错误返回的数据
+func (e *AppError) WithData(data any) *AppError {
+	e.Data = data
+	return e
+}
+
+// HTTPStatus 响应使用的 HTTP 状态码
+func (e *AppError) HTTPStatus() int {
+	return HTTPStatus(e.Code)
+}
+
+// Message 返回给客户端的提示
+func (e *AppError) Message(lang Lang) string {
+	msg := Message(e.Code, lang)
+	if e.Detail == "" {
+		return msg
+	}
+	if lang == LangEN {
+		return msg + ": " + e.Detail
+	}
+	return msg + "：" + e.Detail
+}
+
+func (e *AppError) Error() string {
+	msg := fmt.Sprintf("[%d] %s", e.Code, e.Message(LangZH))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// From 把任意错误转换为 AppError，不是 AppError 的按服务器内部错误处理
+func From(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Wrap(CodeServerError, err)
+}
@@ -0,0 +1,27 @@
+package errcode
+
+import "strings"
+
+// Lang 提示语言
+type Lang string
+
+const (
+	LangZH Lang = "zh"
+	LangEN Lang = "en"
+)
+
+// ParseLang 从 Accept-Language 中取第一个支持的语言，都不支持时使用中文
+// 例如 "en-US,en;q=0.9" -> en
+func ParseLang(acceptLanguage string) Lang {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		switch primary {
+		case "zh":
+			return LangZH
+		case "en":
+			return LangEN
+		}
+	}
+	return LangZH
+}
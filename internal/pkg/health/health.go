@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 依赖的状态
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc 检查一个依赖，返回 nil 表示可用
+type CheckFunc func(ctx context.Context) error
+
+// Result 一个依赖的检查结果
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"` // 不可用时服务是否未就绪
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report 所有依赖的检查结果
+type Report struct {
+	Status string   `json:"status"` // 所有关键依赖可用时为 up
+	Checks []Result `json:"checks"`
+}
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+
+	mu   sync.Mutex // 同一个依赖同时只检查一次，其余请求等待并复用结果
+	last *Result
+}
+
+// Checker 依赖检查，结果缓存 ttl，避免探针频繁请求时压垮依赖
+type Checker struct {
+	ttl     time.Duration
+	timeout time.Duration
+	checks  []*check
+}
+
+func NewChecker(ttl, timeout time.Duration) *Checker {
+	return &Checker{ttl: ttl, timeout: timeout}
+}
+
+// Register 注册一个依赖，critical 为 true 时该依赖不可用会让服务未就绪
+func (c *Checker) Register(name string, critical bool, fn CheckFunc) {
+	c.checks = append(c.checks, &check{name: name, critical: critical, fn: fn})
+}
+
+// Run 并发检查所有依赖，缓存未过期的直接使用缓存
+func (c *Checker) Run(ctx context.Context) Report {
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, ch)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, result := range results {
+		if result.Critical && result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, ch *check) Result {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.last != nil && time.Since(ch.last.CheckedAt) < c.ttl {
+		return *ch.last
+	}
+
+	// 探针请求断开不应该让检查结果变成失败并被缓存下来
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+	start := time.Now()
+	err := ch.fn(ctx)
+	result := &Result{
+		Name:      ch.name,
+		Status:    StatusUp,
+		Critical:  ch.critical,
+		LatencyMs: time.Since(start).Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	ch.last = result
+	return *result
+}
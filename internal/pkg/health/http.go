@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPCheck 请求 url，能连上且没有被拒绝鉴权即视为可用
+// 401 / 403 说明密钥有误，其余 4xx 只说明服务可达（如不支持的请求方法），5xx 视为不可用
+func HTTPCheck(client *http.Client, build func(ctx context.Context) (*http.Request, error)) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := build(ctx)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("鉴权失败: HTTP %d", resp.StatusCode)
+		case resp.StatusCode >= 500:
+			return fmt.Errorf("服务异常: HTTP %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// DashScopeRequest 用 API Key 请求 OpenAI 兼容接口的模型列表
+func DashScopeRequest(baseURL, apiKey string) func(ctx context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/models", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		return req, nil
+	}
+}
+
+// XfyunRequest 按讯飞 WebSocket 接口的鉴权规则（HMAC-SHA256 签名 host、date、request-line）签名后请求 rawURL
+// 不升级为 WebSocket，签名错误时服务端返回 401 / 403
+func XfyunRequest(rawURL, apiKey, apiSecret string) func(ctx context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		date := time.Now().UTC().Format(http.TimeFormat)
+		signature := fmt.Sprintf("host: %s\ndate: %s\nGET %s HTTP/1.1", u.Host, date, u.Path)
+		mac := hmac.New(sha256.New, []byte(apiSecret))
+		mac.Write([]byte(signature))
+		authorization := fmt.Sprintf(`api_key="%s", algorithm="hmac-sha256", headers="host date request-line", signature="%s"`,
+			apiKey, base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+		u.Scheme = strings.Replace(u.Scheme, "ws", "http", 1)
+		u.RawQuery = url.Values{
+			"authorization": {base64.StdEncoding.EncodeToString([]byte(authorization))},
+			"date":          {date},
+			"host":          {u.Host},
+		}.Encode()
+		return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	}
+}
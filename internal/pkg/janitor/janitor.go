@@ -0,0 +1,196 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 文件类别
+const (
+	ClassTempUpload = "temp_upload" // 上传音频的临时文件
+	ClassRecording  = "recording"   // 孩子的录音
+	ClassTTSOutput  = "tts_output"  // 回复语音和 TTS 缓存文件
+)
+
+// SweepFunc 删除超过 maxAge 的文件，返回删除的文件数和字节数
+type SweepFunc func(ctx context.Context, maxAge time.Duration) (files int, bytes int64)
+
+// Rule 一类文件的保留时间
+type Rule struct {
+	Class  string
+	MaxAge time.Duration
+	Sweep  SweepFunc
+}
+
+// Stats 一类文件累计回收的空间
+type Stats struct {
+	Files     int64     `json:"files"`
+	Bytes     int64     `json:"bytes"`
+	LastSweep time.Time `json:"last_sweep"`
+}
+
+// Janitor 后台按类别定期清理过期的音频文件，并统计回收的空间
+type Janitor struct {
+	interval time.Duration
+	rules    []Rule
+
+	mu    sync.Mutex
+	stats map[string]*Stats
+
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+func New(interval time.Duration) *Janitor {
+	return &Janitor{
+		interval: interval,
+		stats:    make(map[string]*Stats),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Add 注册一类文件，maxAge <= 0 表示不清理
+func (j *Janitor) Add(rule Rule) {
+	if rule.MaxAge <= 0 || rule.Sweep == nil {
+		return
+	}
+	j.rules = append(j.rules, rule)
+}
+
+// Start 启动后台清理，启动时先清理一次
+func (j *Janitor) Start() {
+	j.startOnce.Do(func() {
+		if j.interval <= 0 || len(j.rules) == 0 {
+			close(j.done)
+			return
+		}
+		go j.loop()
+	})
+}
+
+func (j *Janitor) loop() {
+	defer close(j.done)
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-j.stop
+		cancel()
+	}()
+
+	for {
+		j.RunOnce(ctx)
+		select {
+		case <-ticker.C:
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+// RunOnce 立即清理所有类别
+func (j *Janitor) RunOnce(ctx context.Context) {
+	for _, rule := range j.rules {
+		if ctx.Err() != nil {
+			return
+		}
+		files, bytes := rule.Sweep(ctx, rule.MaxAge)
+		j.record(rule.Class, files, bytes, true)
+		if files > 0 {
+			logrus.Infof("🧹 清理过期%s文件 %d 个，回收 %d 字节", rule.Class, files, bytes)
+		}
+	}
+}
+
+// Record 记录在其他地方删除的文件（如请求结束时删除的临时文件）
+func (j *Janitor) Record(class string, files int, bytes int64) {
+	j.record(class, files, bytes, false)
+}
+
+func (j *Janitor) record(class string, files int, bytes int64, swept bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st, ok := j.stats[class]
+	if !ok {
+		st = &Stats{}
+		j.stats[class] = st
+	}
+	st.Files += int64(files)
+	st.Bytes += bytes
+	if swept {
+		st.LastSweep = time.Now()
+	}
+}
+
+// Stats 各类文件自启动以来回收的空间
+func (j *Janitor) Stats() map[string]Stats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make(map[string]Stats, len(j.stats))
+	for class, st := range j.stats {
+		out[class] = *st
+	}
+	return out
+}
+
+// Close 停止后台清理，等待正在进行的清理结束
+func (j *Janitor) Close() {
+	j.closeOnce.Do(func() {
+		close(j.stop)
+		j.startOnce.Do(func() { close(j.done) })
+		<-j.done
+	})
+}
+
+// SweepDir 删除目录下修改时间超过 maxAge 的文件，目录不存在时不做任何事
+func SweepDir(dir string) SweepFunc {
+	return func(ctx context.Context, maxAge time.Duration) (files int, bytes int64) {
+		deadline := time.Now().Add(-maxAge)
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil || info.ModTime().After(deadline) {
+				return nil
+			}
+			if err := os.Remove(path); err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					logrus.Warnf("清理过期文件失败 %s: %v", path, err)
+				}
+				return nil
+			}
+			files++
+			bytes += info.Size()
+			return nil
+		})
+		return files, bytes
+	}
+}
+
+// RemoveFile 删除单个文件，返回释放的字节数
+func RemoveFile(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(path); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ChainLink 模型链中的一环
+type ChainLink struct {
+	Model         string
+	Service       LLMService
+	LatencyBudget time.Duration // 超过该时间没有回复就切换到下一个模型，0 表示不限制
+}
+
+// ChainLLM 按顺序尝试多个模型，前一个出错、被限流或超出延迟预算时自动切换到下一个
+type ChainLLM struct {
+	links []ChainLink
+}
+
+func NewChainLLM(links ...ChainLink) *ChainLLM {
+	return &ChainLLM{links: links}
+}
+
+func (c *ChainLLM) Chat(ctx context.Context, prompt string) (*Reply, error) {
+	var errs []error
+	for i, link := range c.links {
+		reply, err := c.try(ctx, link, prompt)
+		if err == nil {
+			if i > 0 {
+				logrus.WithContext(ctx).Warnf("🔀 LLM 已切换到备用模型 %s 回答", link.Model)
+			}
+			return reply, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", link.Model, err))
+		// 客户端已经断开，没必要再试下一个模型
+		if ctx.Err() != nil {
+			break
+		}
+		logrus.WithContext(ctx).Warnf("LLM 模型 %s 调用失败: %v", link.Model, err)
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("llm: 没有配置可用的模型")
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (c *ChainLLM) try(ctx context.Context, link ChainLink, prompt string) (*Reply, error) {
+	// 预算用完时下层看到的是调用方的 ctx 已经结束，不会重试也不计入该模型的熔断，由 Chat 直接换下一个模型
+	if link.LatencyBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, link.LatencyBudget)
+		defer cancel()
+	}
+	reply, err := link.Service.Chat(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Model == "" {
+		reply.Model = link.Model
+	}
+	return reply, nil
+}
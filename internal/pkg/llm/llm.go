@@ -2,6 +2,15 @@ package llm
 
 import "context"
 
+// Reply LLM 的一次回复
+type Reply struct {
+	Content string
+	Model   string // 实际给出回复的模型
+	// 本次调用消耗的 token 数，用于用量统计
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
 type LLMService interface {
-	Chat(ctx context.Context, prompt string) (string, error)
+	Chat(ctx context.Context, prompt string) (*Reply, error)
 }
@@ -2,10 +2,14 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/trace"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 type QwenLLM struct {
@@ -13,18 +17,48 @@ type QwenLLM struct {
 	model  string
 }
 
-func NewQwenLLM(conf *config.AliyunConfig) *QwenLLM {
+// NewQwenLLM 创建一个模型的客户端，model.BaseURL 为空时使用 LLM.base_url
+func NewQwenLLM(conf *config.AliyunConfig, model config.LLMModelConfig) *QwenLLM {
+	baseURL := model.BaseURL
+	if baseURL == "" {
+		baseURL = conf.LLM.BaseURL
+	}
 	client := openai.NewClient(
 		option.WithAPIKey(conf.DASHSCOPE_API_KEY),
-		option.WithBaseURL(conf.LLM.BaseURL),
+		option.WithBaseURL(baseURL),
+		// 重试由 resilience 层统一负责，避免两层重试叠加
+		option.WithMaxRetries(0),
 	)
 	return &QwenLLM{
 		client: client,
-		model:  conf.LLM.Model,
+		model:  model.Model,
 	}
 }
 
-func (q *QwenLLM) Chat(ctx context.Context, prompt string) (string, error) {
+// Model 模型名称
+func (q *QwenLLM) Model() string {
+	return q.model
+}
+
+func (q *QwenLLM) Chat(ctx context.Context, prompt string) (reply *Reply, err error) {
+	ctx, span := trace.Tracer("llm").Start(ctx, "llm.chat", oteltrace.WithAttributes(trace.AttrModel.String(q.model)))
+	defer func() {
+		if err != nil {
+			var apiErr *openai.Error
+			code := ""
+			if errors.As(err, &apiErr) {
+				code = apiErr.Code
+			}
+			trace.Fail(span, err, code)
+		} else {
+			span.SetAttributes(
+				attribute.Int64("llm.prompt_tokens", reply.PromptTokens),
+				attribute.Int64("llm.completion_tokens", reply.CompletionTokens),
+			)
+		}
+		span.End()
+	}()
+
 	// 这里的 System Prompt 是为了体现 PRD 中“耐心英语老师”的角色设定
 	chatCompletion, err := q.client.Chat.Completions.New(
 		ctx,
@@ -37,7 +71,15 @@ func (q *QwenLLM) Chat(ctx context.Context, prompt string) (string, error) {
 		},
 	)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if len(chatCompletion.Choices) == 0 {
+		return nil, errors.New("llm: 模型没有返回任何内容")
 	}
-	return chatCompletion.Choices[0].Message.Content, nil
+	return &Reply{
+		Content:          chatCompletion.Choices[0].Message.Content,
+		Model:            q.model,
+		PromptTokens:     chatCompletion.Usage.PromptTokens,
+		CompletionTokens: chatCompletion.Usage.CompletionTokens,
+	}, nil
 }
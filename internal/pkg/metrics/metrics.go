@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"oktalk/internal/pkg/errcode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "oktalk"
+
+// 语音对话各阶段耗时的分桶：ASR、TTS 一般在 0.2~5 秒，LLM 和整轮对话可能更长
+var pipelineBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 8, 13, 20, 30}
+
+var (
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP 请求耗时，按路由和状态码划分",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	asrDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "asr_duration_seconds",
+		Help:      "语音识别耗时",
+		Buckets:   pipelineBuckets,
+	}, []string{"status"})
+	asrFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "asr_failures_total",
+		Help:      "语音识别失败次数，按业务码划分",
+	}, []string{"code"})
+
+	llmDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_duration_seconds",
+		Help:      "生成回复耗时（包括模型链降级）",
+		Buckets:   pipelineBuckets,
+	}, []string{"status"})
+	llmFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_failures_total",
+		Help:      "生成回复失败次数，按业务码划分",
+	}, []string{"code"})
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "消耗的 token 数，type 为 prompt / completion",
+	}, []string{"model", "type"})
+
+	ttsDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tts_duration_seconds",
+		Help:      "语音合成耗时，流式合成为整段语音输出完的时间",
+		Buckets:   pipelineBuckets,
+	}, []string{"mode", "status"})
+	ttsAudioBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tts_audio_bytes_total",
+		Help:      "合成输出的音频字节数",
+	}, []string{"mode"})
+
+	turnDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "voice_turn_duration_seconds",
+		Help:      "一轮语音对话的端到端耗时，outcome 为 ok / silent / blocked / error",
+		Buckets:   pipelineBuckets,
+	}, []string{"mode", "outcome"})
+
+	activeStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_voice_streams",
+		Help:      "正在推送回复语音的 SSE 连接数",
+	})
+)
+
+// 语音对话的模式
+const (
+	ModeSync   = "sync"
+	ModeStream = "stream"
+)
+
+// 一轮对话的结果
+const (
+	OutcomeOK      = "ok"
+	OutcomeSilent  = "silent"
+	OutcomeBlocked = "blocked"
+	OutcomeError   = "error"
+)
+
+// ObserveHTTP 记录一次 HTTP 请求，route 为路由模板（如 /api/v1/conversations/:id）
+func ObserveHTTP(method, route string, status int, elapsed time.Duration) {
+	httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(elapsed.Seconds())
+}
+
+// ObserveASR 记录一次语音识别，err 为映射后的业务错误，成功时为 nil
+func ObserveASR(elapsed time.Duration, err *errcode.AppError) {
+	asrDuration.WithLabelValues(status(err != nil)).Observe(elapsed.Seconds())
+	if err != nil {
+		asrFailures.WithLabelValues(strconv.Itoa(err.Code)).Inc()
+	}
+}
+
+// ObserveLLM 记录一次回复生成，成功时记录各模型消耗的 token
+func ObserveLLM(elapsed time.Duration, model string, promptTokens, completionTokens int64, err *errcode.AppError) {
+	llmDuration.WithLabelValues(status(err != nil)).Observe(elapsed.Seconds())
+	if err != nil {
+		llmFailures.WithLabelValues(strconv.Itoa(err.Code)).Inc()
+		return
+	}
+	llmTokens.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	llmTokens.WithLabelValues(model, "completion").Add(float64(completionTokens))
+}
+
+// ObserveTTS 记录一次语音合成
+func ObserveTTS(mode string, elapsed time.Duration, audioBytes int, err error) {
+	ttsDuration.WithLabelValues(mode, status(err != nil)).Observe(elapsed.Seconds())
+	ttsAudioBytes.WithLabelValues(mode).Add(float64(audioBytes))
+}
+
+// ObserveTurn 记录一轮语音对话的端到端耗时
+func ObserveTurn(mode, outcome string, elapsed time.Duration) {
+	turnDuration.WithLabelValues(mode, outcome).Observe(elapsed.Seconds())
+}
+
+// StreamStarted 一个 SSE 回复开始推送，推送结束时调用返回的函数
+func StreamStarted() (finished func()) {
+	activeStreams.Inc()
+	return activeStreams.Dec
+}
+
+func status(failed bool) string {
+	if failed {
+		return "error"
+	}
+	return "ok"
+}
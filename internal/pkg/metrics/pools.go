@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"oktalk/internal/pkg/dashscope"
+	"oktalk/internal/pkg/janitor"
+	"oktalk/internal/pkg/tts"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+)
+
+// RegisterDB 注册数据库连接池指标（go_sql_* ，db_name="mysql"）
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "mysql"))
+}
+
+// RegisterRedis 注册 Redis 连接池指标
+func RegisterRedis(rdb *redis.Client) {
+	conns := prometheus.NewDesc(namespace+"_redis_pool_connections", "Redis 连接数，state 为 idle / total", []string{"state"}, nil)
+	gets := prometheus.NewDesc(namespace+"_redis_pool_gets_total", "从 Redis 连接池取连接的次数，result 为 hit / miss / timeout", []string{"result"}, nil)
+	prometheus.MustRegister(collectorFunc{desc: conns, extra: []*prometheus.Desc{gets}, collect: func(ch chan<- prometheus.Metric) {
+		stats := rdb.PoolStats()
+		ch <- prometheus.MustNewConstMetric(conns, prometheus.GaugeValue, float64(stats.IdleConns), "idle")
+		ch <- prometheus.MustNewConstMetric(conns, prometheus.GaugeValue, float64(stats.TotalConns), "total")
+		ch <- prometheus.MustNewConstMetric(gets, prometheus.CounterValue, float64(stats.Hits), "hit")
+		ch <- prometheus.MustNewConstMetric(gets, prometheus.CounterValue, float64(stats.Misses), "miss")
+		ch <- prometheus.MustNewConstMetric(gets, prometheus.CounterValue, float64(stats.Timeouts), "timeout")
+	}})
+}
+
+// RegisterDashScope 注册 DashScope WebSocket 连接池指标，in_use 即正在进行的 ASR / TTS 会话数
+func RegisterDashScope(pool *dashscope.Pool) {
+	desc := prometheus.NewDesc(namespace+"_dashscope_ws_connections", "DashScope WebSocket 连接数，state 为 idle / in_use", []string{"state"}, nil)
+	prometheus.MustRegister(collectorFunc{desc: desc, collect: func(ch chan<- prometheus.Metric) {
+		idle, open := pool.Stats()
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(idle), "idle")
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(open-idle), "in_use")
+	}})
+}
+
+// RegisterJanitor 注册过期音频清理回收的空间
+func RegisterJanitor(j *janitor.Janitor) {
+	files := prometheus.NewDesc(namespace+"_storage_reclaimed_files_total", "清理的音频文件数，按文件类别划分", []string{"class"}, nil)
+	bytes := prometheus.NewDesc(namespace+"_storage_reclaimed_bytes_total", "清理音频回收的字节数，按文件类别划分", []string{"class"}, nil)
+	prometheus.MustRegister(collectorFunc{desc: files, extra: []*prometheus.Desc{bytes}, collect: func(ch chan<- prometheus.Metric) {
+		for class, st := range j.Stats() {
+			ch <- prometheus.MustNewConstMetric(files, prometheus.CounterValue, float64(st.Files), class)
+			ch <- prometheus.MustNewConstMetric(bytes, prometheus.CounterValue, float64(st.Bytes), class)
+		}
+	}})
+}
+
+// RegisterTTSCache 注册 TTS 缓存的命中统计
+func RegisterTTSCache(cache *tts.CachedTTS) {
+	desc := prometheus.NewDesc(namespace+"_tts_cache_requests_total", "TTS 缓存查询次数，result 为 hit / miss / error", []string{"result"}, nil)
+	prometheus.MustRegister(collectorFunc{desc: desc, collect: func(ch chan<- prometheus.Metric) {
+		stats := cache.Stats()
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(stats.Hits), "hit")
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(stats.Misses), "miss")
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(stats.Errors), "error")
+	}})
+}
+
+// collectorFunc 抓取时才读取数据的 Collector
+type collectorFunc struct {
+	desc    *prometheus.Desc
+	extra   []*prometheus.Desc
+	collect func(ch chan<- prometheus.Metric)
+}
+
+func (c collectorFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+	for _, desc := range c.extra {
+		ch <- desc
+	}
+}
+
+func (c collectorFunc) Collect(ch chan<- prometheus.Metric) {
+	c.collect(ch)
+}
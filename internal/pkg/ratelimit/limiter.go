@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"oktalk/internal/pkg/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const limiterKeyPrefix = "ratelimit:"
+
+// tokenBucket 令牌桶，状态保存在 Redis hash 中（tokens: 剩余令牌，ts: 上次更新时间）
+// 使用 Redis 的 TIME 作为时钟，多个实例之间不受本机时间偏差影响
+// 返回 {是否放行, 需要等待的毫秒数}
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, wait}
+`)
+
+// Limiter 基于 Redis 的分布式令牌桶限流器
+type Limiter struct {
+	rdb *redis.Client
+}
+
+func NewLimiter(rdb *redis.Client) *Limiter {
+	return &Limiter{rdb: rdb}
+}
+
+// Allow 从 key 对应的桶里取一个令牌，取不到时返回需要等待的时间
+// rule.PerMinute <= 0 表示不限制
+func (l *Limiter) Allow(ctx context.Context, key string, rule config.RateRule) (bool, time.Duration, error) {
+	if rule.PerMinute <= 0 {
+		return true, 0, nil
+	}
+	burst := rule.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	rate := float64(rule.PerMinute) / 60
+	res, err := tokenBucket.Run(ctx, l.rdb, []string{limiterKeyPrefix + key}, rate, burst).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const quotaKeyPrefix = "quota:"
+
+// DailyUsage 当天已经使用的额度
+type DailyUsage struct {
+	Turns        int64 // 对话轮数
+	VoiceSeconds int64 // 孩子说话的音频时长（秒）
+}
+
+// Quota 按自然日统计的用量计数器，每个主体每天一个 Redis hash，第二天自动换新 key
+type Quota struct {
+	rdb *redis.Client
+}
+
+func NewQuota(rdb *redis.Client) *Quota {
+	return &Quota{rdb: rdb}
+}
+
+// Usage 读取主体当天的用量
+func (q *Quota) Usage(ctx context.Context, subject string) (DailyUsage, error) {
+	vals, err := q.rdb.HMGet(ctx, dailyKey(subject, time.Now()), "turns", "voice_seconds").Result()
+	if err != nil {
+		return DailyUsage{}, err
+	}
+	var usage DailyUsage
+	usage.Turns, err = toInt64(vals[0])
+	if err != nil {
+		return DailyUsage{}, err
+	}
+	usage.VoiceSeconds, err = toInt64(vals[1])
+	return usage, err
+}
+
+// Add 累加主体当天的用量
+func (q *Quota) Add(ctx context.Context, subject string, turns, voiceSeconds int64) error {
+	key := dailyKey(subject, time.Now())
+	pipe := q.rdb.TxPipeline()
+	pipe.HIncrBy(ctx, key, "turns", turns)
+	pipe.HIncrBy(ctx, key, "voice_seconds", voiceSeconds)
+	// 当天结束后 key 就不再使用，多留一天便于排查
+	pipe.Expire(ctx, key, 48*time.Hour)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// UntilReset 距离额度重置（本地时间零点）还有多久
+func UntilReset(now time.Time) time.Duration {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Sub(now)
+}
+
+func dailyKey(subject string, now time.Time) string {
+	return quotaKeyPrefix + now.Format("20060102") + ":" + subject
+}
+
+func toInt64(v any) (int64, error) {
+	s, ok := v.(string)
+	if !ok {
+		// 字段不存在
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
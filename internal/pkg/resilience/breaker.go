@@ -0,0 +1,118 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器处于打开状态，请求被直接拒绝
+var ErrCircuitOpen = errors.New("resilience: 服务熔断中")
+
+type breakerState int
+
+const (
+	stateClosed   breakerState = iota // 正常放行
+	stateOpen                         // 熔断，直接拒绝
+	stateHalfOpen                     // 试探，只放行一个请求
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker 连续失败计数熔断器
+// 连续失败 threshold 次后打开，openDuration 之后进入半开状态放行一个试探请求，成功则恢复，失败则继续熔断
+type Breaker struct {
+	threshold    int
+	openDuration time.Duration
+
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	onChanged func(from, to string)
+}
+
+// NewBreaker threshold <= 0 时熔断器永远不会打开
+func NewBreaker(threshold int, openDuration time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, openDuration: openDuration}
+}
+
+// Allow 判断请求能否放行
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.openDuration {
+			return ErrCircuitOpen
+		}
+		b.setState(stateHalfOpen)
+		b.probing = true
+		return nil
+	case stateHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success 记录一次成功
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	if b.state != stateClosed {
+		b.setState(stateClosed)
+	}
+}
+
+// Failure 记录一次服务端故障（调用方自身的错误不要记录）
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == stateHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		if b.state != stateOpen {
+			b.setState(stateOpen)
+		}
+	}
+}
+
+// Release 放行的请求既不算成功也不算失败（如被调用方取消）时调用，归还半开状态的试探名额
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// State 当前状态：closed / open / half-open
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.String()
+}
+
+func (b *Breaker) setState(to breakerState) {
+	from := b.state
+	b.state = to
+	if b.onChanged != nil {
+		b.onChanged(from.String(), to.String())
+	}
+}
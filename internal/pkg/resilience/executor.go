@@ -0,0 +1,157 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"oktalk/internal/pkg/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Outcome 一次调用失败的性质
+type Outcome int
+
+const (
+	// Fatal 调用方自身的问题（参数错误、音频损坏、请求取消等），不重试、不计入熔断
+	Fatal Outcome = iota
+	// Failure 服务端故障但重试大概率无用（如鉴权失败），不重试、计入熔断
+	Failure
+	// Retryable 临时故障（超时、限流、网络抖动），重试并计入熔断
+	Retryable
+)
+
+// Classifier 判断错误的性质，各 provider 按自己的错误类型实现
+type Classifier func(err error) Outcome
+
+// Executor 按策略执行调用：熔断检查 -> 单次超时 -> 失败后指数退避重试
+type Executor struct {
+	name     string
+	policy   config.ProviderPolicy
+	breaker  *Breaker
+	classify Classifier
+}
+
+func NewExecutor(name string, policy config.ProviderPolicy, classify Classifier) *Executor {
+	breaker := NewBreaker(policy.Breaker.FailureThreshold, time.Duration(policy.Breaker.OpenSeconds)*time.Second)
+	breaker.onChanged = func(from, to string) {
+		logrus.Warnf("⚡ %s 熔断器状态变化: %s -> %s", name, from, to)
+	}
+	return &Executor{
+		name:     name,
+		policy:   policy,
+		breaker:  breaker,
+		classify: classify,
+	}
+}
+
+// Breaker 返回执行器使用的熔断器
+func (e *Executor) Breaker() *Breaker {
+	return e.breaker
+}
+
+// Do 执行 fn，fn 收到的 ctx 带有单次调用的超时
+func (e *Executor) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return e.do(ctx, false, fn)
+}
+
+// DoStream 用于返回流的调用：不设置单次超时（流的生命周期长于本次调用），
+// fn 成功时也不记为成功，调用方必须在流结束时调用 Record 记录最终结果，否则半开状态的试探名额不会归还
+func (e *Executor) DoStream(ctx context.Context, fn func(ctx context.Context) error) error {
+	return e.do(ctx, true, fn)
+}
+
+// Record 按错误分类把一次调用的最终结果记到熔断器上
+func (e *Executor) Record(err error) {
+	switch {
+	case err == nil:
+		e.breaker.Success()
+	case e.classify(err) == Fatal:
+		e.breaker.Release()
+	default:
+		e.breaker.Failure()
+	}
+}
+
+func (e *Executor) do(ctx context.Context, stream bool, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt <= e.policy.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, e.backoff(attempt)); err != nil {
+				return err
+			}
+			logrus.WithContext(ctx).Warnf("🔁 %s 第 %d 次重试，上次错误: %v", e.name, attempt, err)
+		}
+		if allowErr := e.breaker.Allow(); allowErr != nil {
+			if err != nil {
+				return errors.Join(allowErr, err)
+			}
+			return allowErr
+		}
+
+		err = e.attempt(ctx, !stream, fn)
+		if err == nil {
+			if !stream {
+				e.breaker.Success()
+			}
+			return nil
+		}
+		// 调用方传入的 ctx 已经结束（客户端断开，或模型链的延迟预算用完），
+		// 这是调用方放弃等待，不是服务故障：不计入熔断，也不在已经结束的 ctx 上重试
+		if ctx.Err() != nil {
+			e.breaker.Release()
+			return err
+		}
+
+		switch e.classify(err) {
+		case Fatal:
+			e.breaker.Release()
+			return err
+		case Failure:
+			e.breaker.Failure()
+			return err
+		default:
+			e.breaker.Failure()
+		}
+	}
+	return err
+}
+
+func (e *Executor) attempt(ctx context.Context, withTimeout bool, fn func(ctx context.Context) error) error {
+	if !withTimeout || e.policy.TimeoutSeconds <= 0 {
+		return fn(ctx)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, time.Duration(e.policy.TimeoutSeconds)*time.Second)
+	defer cancel()
+	return fn(attemptCtx)
+}
+
+// backoff 指数退避 + 随机抖动：base * 2^(attempt-1)，不超过 max
+func (e *Executor) backoff(attempt int) time.Duration {
+	base := time.Duration(e.policy.BackoffBaseMs) * time.Millisecond
+	if base <= 0 {
+		return 0
+	}
+	d := base << (attempt - 1)
+	if limit := time.Duration(e.policy.BackoffMaxMs) * time.Millisecond; limit > 0 && (d > limit || d <= 0) {
+		d = limit
+	}
+	// 在 [d/2, d) 之间随机，避免大量请求同时重试
+	return d/2 + rand.N(d/2+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,218 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+
+	"oktalk/internal/pkg/asr"
+	"oktalk/internal/pkg/llm"
+	"oktalk/internal/pkg/tts"
+
+	"github.com/openai/openai-go/v3"
+)
+
+// noRetryError 标记无法重试的失败（如音频流已经被读走），classify 时按 Fatal 处理
+type noRetryError struct {
+	err error
+}
+
+func (e *noRetryError) Error() string { return e.err.Error() }
+func (e *noRetryError) Unwrap() error { return e.err }
+
+// classifyCommon 各 provider 通用的判断，返回 false 表示需要交给具体 provider 判断
+func classifyCommon(err error) (Outcome, bool) {
+	var noRetry *noRetryError
+	switch {
+	case errors.As(err, &noRetry):
+		return Fatal, true
+	case errors.Is(err, context.Canceled):
+		return Fatal, true
+	case errors.Is(err, context.DeadlineExceeded):
+		return Retryable, true
+	case errors.Is(err, ErrCircuitOpen):
+		return Fatal, true
+	}
+	return 0, false
+}
+
+// ClassifyASR ASR 错误分类
+func ClassifyASR(err error) Outcome {
+	if outcome, ok := classifyCommon(err); ok {
+		return outcome
+	}
+	var taskErr *asr.TaskError
+	switch {
+	case errors.Is(err, asr.ErrBadAudio), errors.Is(err, fs.ErrNotExist):
+		return Fatal
+	case errors.Is(err, asr.ErrAuthFailed):
+		return Failure
+	case errors.Is(err, asr.ErrTimeout), errors.Is(err, asr.ErrQuotaExceeded):
+		return Retryable
+	case errors.As(err, &taskErr):
+		// 其他 task-failed 多半与本次请求有关，重试意义不大
+		return Failure
+	default:
+		// 连接失败、读写失败等网络问题
+		return Retryable
+	}
+}
+
+// ClassifyLLM LLM 错误分类，按 OpenAI 兼容接口的 HTTP 状态码判断
+func ClassifyLLM(err error) Outcome {
+	if outcome, ok := classifyCommon(err); ok {
+		return outcome
+	}
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests, apiErr.StatusCode >= 500:
+			return Retryable
+		case apiErr.StatusCode == http.StatusUnauthorized, apiErr.StatusCode == http.StatusForbidden:
+			return Failure
+		default:
+			return Fatal
+		}
+	}
+	return Retryable
+}
+
+// ClassifyTTS TTS 错误分类
+func ClassifyTTS(err error) Outcome {
+	if outcome, ok := classifyCommon(err); ok {
+		return outcome
+	}
+	var taskErr *tts.TaskError
+	switch {
+	case errors.Is(err, tts.ErrInvalidOptions), errors.Is(err, tts.ErrSSMLSegmentLimit):
+		return Fatal
+	case errors.Is(err, tts.ErrTimeout):
+		return Retryable
+	case errors.As(err, &taskErr):
+		return Failure
+	default:
+		return Retryable
+	}
+}
+
+// ASR 带超时、重试、熔断的 ASRService
+type ASR struct {
+	next asr.ASRService
+	ex   *Executor
+}
+
+func NewASR(next asr.ASRService, ex *Executor) *ASR {
+	return &ASR{next: next, ex: ex}
+}
+
+func (a *ASR) RecognizeOnce(ctx context.Context, audioPath string) (string, error) {
+	result, err := a.Recognize(ctx, audioPath)
+	if err != nil || result == nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+func (a *ASR) Recognize(ctx context.Context, audioPath string) (*asr.Result, error) {
+	var result *asr.Result
+	err := a.ex.Do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = a.next.Recognize(ctx, audioPath)
+		return err
+	})
+	return result, err
+}
+
+// RecognizeReader 只有 r 支持 Seek 时才会重试，否则音频已经被读走，失败直接返回
+func (a *ASR) RecognizeReader(ctx context.Context, r io.Reader, pacing asr.Pacing) (*asr.Result, error) {
+	var (
+		result  *asr.Result
+		lastErr error
+		start   int64
+	)
+	seeker, seekable := r.(io.Seeker)
+	if seekable {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			seekable = false
+		}
+		start = offset
+	}
+
+	attempted := false
+	err := a.ex.Do(ctx, func(ctx context.Context) error {
+		if attempted {
+			if !seekable {
+				return &noRetryError{err: lastErr}
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return &noRetryError{err: lastErr}
+			}
+		}
+		attempted = true
+		result, lastErr = a.next.RecognizeReader(ctx, r, pacing)
+		return lastErr
+	})
+	return result, err
+}
+
+// LLM 带超时、重试、熔断的 LLMService
+type LLM struct {
+	next llm.LLMService
+	ex   *Executor
+}
+
+func NewLLM(next llm.LLMService, ex *Executor) *LLM {
+	return &LLM{next: next, ex: ex}
+}
+
+func (l *LLM) Chat(ctx context.Context, prompt string) (*llm.Reply, error) {
+	var reply *llm.Reply
+	err := l.ex.Do(ctx, func(ctx context.Context) error {
+		var err error
+		reply, err = l.next.Chat(ctx, prompt)
+		return err
+	})
+	return reply, err
+}
+
+// TTS 带超时、重试、熔断的 TTSService
+// 熔断打开时立即返回 ErrCircuitOpen，ChatService 会降级为纯文本回复
+type TTS struct {
+	next tts.TTSService
+	ex   *Executor
+}
+
+func NewTTS(next tts.TTSService, ex *Executor) *TTS {
+	return &TTS{next: next, ex: ex}
+}
+
+func (t *TTS) Synthesize(ctx context.Context, text string, opts tts.SynthesisOptions) (*tts.Synthesis, error) {
+	var synthesis *tts.Synthesis
+	err := t.ex.Do(ctx, func(ctx context.Context) error {
+		var err error
+		synthesis, err = t.next.Synthesize(ctx, text, opts)
+		return err
+	})
+	return synthesis, err
+}
+
+// SynthesizeStream 只对启动合成任务做重试，流开始输出之后的错误通过 AudioStream.Err 返回，
+// 流结束时再按最终结果计入熔断
+func (t *TTS) SynthesizeStream(ctx context.Context, segments <-chan string, opts tts.SynthesisOptions) (*tts.AudioStream, error) {
+	var stream *tts.AudioStream
+	err := t.ex.DoStream(ctx, func(ctx context.Context) error {
+		var err error
+		stream, err = tts.Stream(ctx, t.next, segments, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		t.ex.Record(stream.Err())
+	}()
+	return stream, nil
+}
@@ -0,0 +1,111 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/llm"
+	"oktalk/internal/pkg/tts"
+)
+
+// stubTTS err 为空时返回固定音频，否则返回 err；经 tts.Stream 逐段合成时错误在流开始后才出现
+type stubTTS struct {
+	err error
+}
+
+func (s stubTTS) Synthesize(ctx context.Context, text string, opts tts.SynthesisOptions) (*tts.Synthesis, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &tts.Synthesis{Audio: []byte{1, 2}}, nil
+}
+
+func TestTTSStreamRecordsFinalError(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		state string
+	}{
+		{"服务端故障计入熔断", &tts.TaskError{Code: "InternalError"}, "open"},
+		{"调用方取消不计入熔断", context.Canceled, "closed"},
+		{"正常结束", nil, "closed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := config.ProviderPolicy{Breaker: config.BreakerConfig{FailureThreshold: 1, OpenSeconds: 60}}
+			ex := NewExecutor("tts", policy, ClassifyTTS)
+			svc := NewTTS(stubTTS{err: tc.err}, ex)
+
+			segments := make(chan string, 1)
+			segments <- "你好"
+			close(segments)
+			stream, err := svc.SynthesizeStream(context.Background(), segments, tts.SynthesisOptions{})
+			if err != nil {
+				t.Fatalf("启动合成失败: %v", err)
+			}
+			for range stream.Chunks {
+			}
+			if got := stream.Err(); !errors.Is(got, tc.err) {
+				t.Fatalf("Err() = %v，期望 %v", got, tc.err)
+			}
+
+			// 熔断结果在流结束后由另一个协程记录
+			deadline := time.Now().Add(time.Second)
+			for ex.Breaker().State() != tc.state && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if got := ex.Breaker().State(); got != tc.state {
+				t.Fatalf("熔断器状态 = %s，期望 %s", got, tc.state)
+			}
+		})
+	}
+}
+
+// slowLLM 一直等到 ctx 结束，记录被调用的次数
+type slowLLM struct {
+	calls int
+}
+
+func (s *slowLLM) Chat(ctx context.Context, prompt string) (*llm.Reply, error) {
+	s.calls++
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// fixedLLM 立即返回固定回复
+type fixedLLM struct{}
+
+func (fixedLLM) Chat(ctx context.Context, prompt string) (*llm.Reply, error) {
+	return &llm.Reply{Content: "hi"}, nil
+}
+
+// 模型链的延迟预算用完时不重试、不计入熔断，直接切换到下一个模型
+func TestLLMChainLatencyBudget(t *testing.T) {
+	policy := config.ProviderPolicy{
+		MaxRetries: 2,
+		Breaker:    config.BreakerConfig{FailureThreshold: 1, OpenSeconds: 60},
+	}
+	slow := &slowLLM{}
+	ex := NewExecutor("llm:slow", policy, ClassifyLLM)
+	chain := llm.NewChainLLM(
+		llm.ChainLink{Model: "slow", Service: NewLLM(slow, ex), LatencyBudget: 20 * time.Millisecond},
+		llm.ChainLink{Model: "fast", Service: NewLLM(fixedLLM{}, NewExecutor("llm:fast", policy, ClassifyLLM))},
+	)
+
+	reply, err := chain.Chat(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if reply.Model != "fast" {
+		t.Errorf("reply.Model = %q，期望切换到 fast", reply.Model)
+	}
+	if slow.calls != 1 {
+		t.Errorf("slow 被调用 %d 次，预算用完后不应重试", slow.calls)
+	}
+	if got := ex.Breaker().State(); got != "closed" {
+		t.Errorf("slow 的熔断器状态 = %s，预算用完不应计入熔断", got)
+	}
+}
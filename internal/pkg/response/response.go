@@ -1,10 +1,15 @@
 package response
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+
 	"oktalk/internal/pkg/constants"
+	"oktalk/internal/pkg/errcode"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // Response 统一 JSON 结构
@@ -25,3 +30,27 @@ func SendJSON(c *gin.Context, businessCode int, data interface{}, msg string) {
 		Data:    data,
 	})
 }
+
+// Error 把错误写成统一响应，HTTP 状态码和业务码来自 errcode，提示语言按 Accept-Language 选择
+// 不是 AppError 的错误按服务器内部错误返回，原始错误只写日志
+func Error(c *gin.Context, err error) {
+	appErr := errcode.From(err)
+	if appErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+	c.JSON(appErr.HTTPStatus(), ErrorBody(c, appErr))
+}
+
+// ErrorBody 错误对应的响应内容，SSE 的 error 事件也使用它
+func ErrorBody(c *gin.Context, err error) Response {
+	appErr := errcode.From(err)
+	if appErr.Code == errcode.CodeServerError && appErr.Err != nil {
+		logrus.WithContext(c.Request.Context()).Errorf("❌ 服务器内部错误: %v", appErr.Err)
+	}
+	return Response{
+		TraceID: c.GetString(constants.TraceIDKey),
+		Code:    appErr.Code,
+		Msg:     appErr.Message(errcode.ParseLang(c.GetHeader("Accept-Language"))),
+		Data:    appErr.Data,
+	}
+}
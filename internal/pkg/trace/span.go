@@ -0,0 +1,40 @@
+package trace
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// 各阶段 span 共用的属性
+const (
+	AttrModel     = attribute.Key("oktalk.model")
+	AttrTaskID    = attribute.Key("dashscope.task_id")
+	AttrErrorCode = attribute.Key("error_code")
+)
+
+// 识别、合成任务的 span 事件，对应 DashScope 的任务事件
+const (
+	EventTaskStarted  = "task-started"
+	EventFirstResult  = "first-result"
+	EventTaskFinished = "task-finished"
+)
+
+// Tracer 返回模块的 tracer，name 如 "asr"、"llm"
+// 每次调用时从全局 provider 获取，InitOpenTelemetry 之前创建的也能正常导出
+func Tracer(name string) oteltrace.Tracer {
+	return otel.Tracer(SERVICE_NAME + "/" + name)
+}
+
+// Fail 把 span 标记为失败，code 为服务端返回的错误码，没有时传空字符串
+func Fail(span oteltrace.Span, err error, code string) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	if code != "" {
+		span.SetAttributes(AttrErrorCode.String(code))
+	}
+}
@@ -2,29 +2,56 @@ package trace
 
 import (
 	"context"
+	"fmt"
+	"oktalk/internal/pkg/config"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.15.0"
-	"log"
-	"os"
-	"time"
 )
 
+// SERVICE_NAME 未配置 service_name 时使用的应用名，也是各模块 tracer 名的前缀
+const SERVICE_NAME = "oktalk"
+
+// 支持的 exporter
 const (
-	SERVICE_NAME       = "oktalk"
-	SERVICE_VERSION    = "v1.0"
-	DEPLOY_ENVIRONMENT = "dev"
-	HTTP_ENDPOINT      = "tracing-cn-guangzhou.arms.aliyuncs.com"
-	HTTP_URL_PATH      = "adapt_gbe3nf2fp0@95ac643a4a71ba5_gbe3nf2fp0@53df7ad2afe8301/api/otlp/traces"
+	ExporterOTLPHTTP = "otlp_http"
+	ExporterOTLPGRPC = "otlp_grpc"
+	ExporterStdout   = "stdout"
+	ExporterNone     = "none"
 )
 
 // 设置应用资源
-func newResource(ctx context.Context) *resource.Resource {
+// 主机、进程等探测失败时只告警，使用已经探测到的部分
+func newResource(ctx context.Context, conf *config.TraceConfig) *resource.Resource {
 	hostName, _ := os.Hostname()
+	serviceName := conf.ServiceName
+	if serviceName == "" {
+		serviceName = SERVICE_NAME
+	}
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(serviceName), // 应用名
+		semconv.HostNameKey.String(hostName),       // 主机名
+	}
+	if conf.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(conf.ServiceVersion)) // 应用版本
+	}
+	if conf.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(conf.Environment)) // 部署环境
+	}
+	for k, v := range conf.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
 
 	r, err := resource.New(
 		ctx,
@@ -32,61 +59,132 @@ func newResource(ctx context.Context) *resource.Resource {
 		resource.WithProcess(),
 		resource.WithTelemetrySDK(),
 		resource.WithHost(),
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String(SERVICE_NAME),                 // 应用名
-			semconv.ServiceVersionKey.String(SERVICE_VERSION),           // 应用版本
-			semconv.DeploymentEnvironmentKey.String(DEPLOY_ENVIRONMENT), // 部署环境
-			semconv.HostNameKey.String(hostName),                        // 主机名
-		),
+		resource.WithAttributes(attrs...),
 	)
-
 	if err != nil {
-		log.Fatalf("%s: %v", "Failed to create OpenTelemetry resource", err)
+		logrus.Warnf("⚠️ 创建 OpenTelemetry resource 不完整: %v", err)
+		if r == nil {
+			r = resource.NewSchemaless(attrs...)
+		}
 	}
 	return r
 }
 
-func newHTTPExporterAndSpanProcessor(ctx context.Context) (*otlptrace.Exporter, sdktrace.SpanProcessor) {
+// newExporter 按配置创建 exporter，none 返回 nil
+func newExporter(ctx context.Context, conf *config.TraceConfig) (sdktrace.SpanExporter, error) {
+	timeout := time.Duration(conf.TimeoutSeconds) * time.Second
+	switch strings.ToLower(conf.Exporter) {
+	case ExporterOTLPHTTP:
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(conf.Endpoint),
+			otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
+		}
+		if conf.URLPath != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(conf.URLPath))
+		}
+		if conf.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(conf.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(conf.Headers))
+		}
+		if timeout > 0 {
+			opts = append(opts, otlptracehttp.WithTimeout(timeout))
+		}
+		return otlptracehttp.New(ctx, opts...)
 
-	traceExporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
-		otlptracehttp.WithEndpoint(HTTP_ENDPOINT),
-		otlptracehttp.WithURLPath(HTTP_URL_PATH),
-		otlptracehttp.WithInsecure(),
-		otlptracehttp.WithCompression(1)))
+	case ExporterOTLPGRPC:
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(conf.Endpoint),
+			otlptracegrpc.WithCompressor("gzip"),
+		}
+		if conf.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(conf.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(conf.Headers))
+		}
+		if timeout > 0 {
+			opts = append(opts, otlptracegrpc.WithTimeout(timeout))
+		}
+		return otlptracegrpc.New(ctx, opts...)
 
-	if err != nil {
-		log.Fatalf("%s: %v", "Failed to create the OpenTelemetry trace exporter", err)
-	}
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
 
-	batchSpanProcessor := sdktrace.NewBatchSpanProcessor(traceExporter)
+	case ExporterNone, "":
+		return nil, nil
 
-	return traceExporter, batchSpanProcessor
+	default:
+		return nil, fmt.Errorf("未知的 trace exporter: %s", conf.Exporter)
+	}
+}
+
+// newSampler 按配置创建采样器，名称与 OTEL_TRACES_SAMPLER 的取值一致
+func newSampler(conf *config.TraceConfig) (sdktrace.Sampler, error) {
+	name := strings.ToLower(conf.Sampler)
+	if name == "" {
+		name = "parentbased_always_on"
+	}
+	parentBased := strings.HasPrefix(name, "parentbased_")
+	var sampler sdktrace.Sampler
+	switch strings.TrimPrefix(name, "parentbased_") {
+	case "always_on":
+		sampler = sdktrace.AlwaysSample()
+	case "always_off":
+		sampler = sdktrace.NeverSample()
+	case "traceidratio":
+		if conf.SampleRatio < 0 || conf.SampleRatio > 1 {
+			return nil, fmt.Errorf("sample_ratio 必须在 0~1 之间: %v", conf.SampleRatio)
+		}
+		sampler = sdktrace.TraceIDRatioBased(conf.SampleRatio)
+	default:
+		return nil, fmt.Errorf("未知的 trace sampler: %s", conf.Sampler)
+	}
+	if parentBased {
+		sampler = sdktrace.ParentBased(sampler)
+	}
+	return sampler, nil
 }
 
 // InitOpenTelemetry OpenTelemetry 初始化方法
-func InitOpenTelemetry() func() {
+// exporter 或采样器配置有误时只告警，退化为不导出，不影响服务启动；
+// 返回的 shutdown 会先导出缓冲中的 span 再关闭 exporter，应在其他资源释放之后调用
+func InitOpenTelemetry(conf *config.TraceConfig) func(ctx context.Context) error {
 	ctx := context.Background()
 
-	var traceExporter *otlptrace.Exporter
-	var batchSpanProcessor sdktrace.SpanProcessor
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(newResource(ctx, conf)),
+	}
 
-	traceExporter, batchSpanProcessor = newHTTPExporterAndSpanProcessor(ctx)
+	sampler, err := newSampler(conf)
+	if err != nil {
+		logrus.Warnf("⚠️ trace 采样器配置无效，使用 parentbased_always_on: %v", err)
+		sampler = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+	opts = append(opts, sdktrace.WithSampler(sampler))
+
+	// 没有 exporter 时仍然使用 SDK 的 provider，这样日志和用量记录里的 TraceID 不受影响
+	exporter, err := newExporter(ctx, conf)
+	switch {
+	case err != nil:
+		logrus.Warnf("⚠️ 创建 trace exporter 失败，trace 将不会导出: %v", err)
+	case exporter != nil:
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+		logrus.Infof("✅ trace 导出到 %s %s", conf.Exporter, conf.Endpoint)
+	default:
+		logrus.Info("trace 未配置 exporter，不导出")
+	}
 
-	otelResource := newResource(ctx)
+	traceProvider := sdktrace.NewTracerProvider(opts...)
 
-	traceProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithResource(otelResource),
-		sdktrace.WithSpanProcessor(batchSpanProcessor))
+	// 导出失败（如接收端不可达）只写日志
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logrus.Warnf("⚠️ OpenTelemetry: %v", err)
+	}))
 
 	otel.SetTracerProvider(traceProvider)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
-	return func() {
-		cxt, cancel := context.WithTimeout(ctx, time.Second)
-		defer cancel()
-		if err := traceExporter.Shutdown(cxt); err != nil {
-			otel.Handle(err)
-		}
-	}
+	return traceProvider.Shutdown
 }
@@ -4,22 +4,32 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
 	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/dashscope"
+	"oktalk/internal/pkg/trace"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 type AliyunTTS struct {
 	conf *config.AliyunConfig
+	pool *dashscope.Pool
 }
 
-var dialer = websocket.DefaultDialer
+// 没有配置超时时使用的默认值
+const (
+	defaultStartTimeout      = 10 * time.Second // 等待 task-started
+	defaultSynthesizeTimeout = 40 * time.Second // 非流式合成整体
+)
+
 var wsURL string
 var ttsModel string
 var apiKey string
@@ -36,120 +46,285 @@ type Header struct {
 }
 
 type Payload struct {
-	TaskGroup  string `json:"task_group"`
-	Task       string `json:"task"`
-	Function   string `json:"function"`
-	Model      string `json:"model"`
-	Parameters Params `json:"parameters"`
-	Input      Input  `json:"input"`
+	TaskGroup  string  `json:"task_group"`
+	Task       string  `json:"task"`
+	Function   string  `json:"function"`
+	Model      string  `json:"model"`
+	Parameters Params  `json:"parameters"`
+	Input      Input   `json:"input"`
+	Output     *Output `json:"output,omitempty"`
+	Usage      *Usage  `json:"usage,omitempty"`
+}
+
+// Usage task-finished 事件中的计费信息
+type Usage struct {
+	Characters int `json:"characters"`
 }
 
 type Params struct {
-	TextType   string `json:"text_type"`
-	Voice      string `json:"voice"`
-	Format     string `json:"format"`
-	SampleRate int    `json:"sample_rate"`
-	Volume     int    `json:"volume"`
-	Rate       int    `json:"rate"`
-	Pitch      int    `json:"pitch"`
-	EnableSsml bool   `json:"enable_ssml"`
+	TextType   string  `json:"text_type"`
+	Voice      string  `json:"voice"`
+	Format     string  `json:"format"`
+	SampleRate int     `json:"sample_rate"`
+	Volume     int     `json:"volume"`
+	Rate       float64 `json:"rate"`
+	Pitch      float64 `json:"pitch"`
+	EnableSsml bool    `json:"enable_ssml"`
+	// 开启后 result-generated 事件会携带词级时间戳（仅部分音色支持）
+	WordTimestampEnabled bool `json:"word_timestamp_enabled,omitempty"`
 }
 
 type Input struct {
 	Text string `json:"text,omitempty"`
 }
 
+type Output struct {
+	Sentence OutputSentence `json:"sentence"`
+}
+
+type OutputSentence struct {
+	Index int          `json:"index"`
+	Words []OutputWord `json:"words"`
+}
+
+type OutputWord struct {
+	Text       string `json:"text"`
+	BeginIndex int    `json:"begin_index"`
+	EndIndex   int    `json:"end_index"`
+	BeginTime  int64  `json:"begin_time"`
+	EndTime    int64  `json:"end_time"`
+}
+
 type Event struct {
 	Header  Header  `json:"header"`
 	Payload Payload `json:"payload"`
 }
 
-func NewAliyunTTS(conf *config.AliyunConfig) *AliyunTTS {
+func NewAliyunTTS(conf *config.AliyunConfig, pool *dashscope.Pool) *AliyunTTS {
 	wsURL = conf.TTS.WsURL
 	ttsModel = conf.TTS.Model
 	apiKey = conf.DASHSCOPE_API_KEY
-	return &AliyunTTS{conf: conf}
+	return &AliyunTTS{conf: conf, pool: pool}
 }
 
-// Synthesize 语音合成
-func (p *AliyunTTS) Synthesize(ctx context.Context, text string) ([]byte, error) {
-	// 1. 建立 WebSocket 连接
-	conn, err := connectWebSocket()
+// Synthesize 语音合成，等待整段音频合成完成后一次性返回
+func (p *AliyunTTS) Synthesize(ctx context.Context, text string, opts SynthesisOptions) (*Synthesis, error) {
+	ctx, cancel := context.WithTimeout(ctx, seconds(p.conf.TTS.SynthesizeTimeoutSeconds, defaultSynthesizeTimeout))
+	defer cancel()
+
+	stream, err := p.SynthesizeStream(ctx, Segments(text), opts)
 	if err != nil {
-		return nil, fmt.Errorf("连接 WebSocket 失败: %w", err)
+		return nil, err
+	}
+
+	var audioBuffer bytes.Buffer
+	for chunk := range stream.Chunks {
+		audioBuffer.Write(chunk)
+	}
+	if err := stream.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %w", ErrTimeout, err)
+		}
+		return nil, err
+	}
+	return &Synthesis{Audio: audioBuffer.Bytes(), Words: stream.Words(), Characters: stream.Characters()}, nil
+}
+
+// SynthesizeStream 流式语音合成，一个任务内可以发送多段文本
+func (p *AliyunTTS) SynthesizeStream(ctx context.Context, segments <-chan string, opts SynthesisOptions) (*AudioStream, error) {
+	opts = opts.withDefaults()
+	ctx, span := trace.Tracer("tts").Start(ctx, "tts.synthesize", oteltrace.WithAttributes(
+		trace.AttrModel.String(p.conf.TTS.Model),
+		attribute.String("tts.voice", opts.Voice),
+		attribute.String("tts.format", opts.Format),
+	))
+	if err := opts.validate(); err != nil {
+		failSpan(span, err)
+		return nil, err
+	}
+
+	// 复用的空闲连接可能已被服务端断开，这种情况下换一条新连接重试一次
+	for attempt := 0; ; attempt++ {
+		stream, reused, err := p.startStream(ctx, segments, opts)
+		if err == nil {
+			// 合成结束后再结束 span，音频字节数由接收协程写入
+			go func() {
+				if err := stream.Err(); err != nil {
+					failSpan(span, err)
+					return
+				}
+				span.SetAttributes(attribute.Int("tts.characters", stream.Characters()))
+				span.End()
+			}()
+			return stream, nil
+		}
+		if !reused || attempt > 0 || ctx.Err() != nil {
+			failSpan(span, err)
+			return nil, err
+		}
+		logrus.WithContext(ctx).Warnf("复用的 WebSocket 连接不可用，重新建立连接: %v", err)
+	}
+}
+
+// seconds 配置的秒数，未配置时使用默认值
+func seconds(n int, def time.Duration) time.Duration {
+	if n <= 0 {
+		return def
+	}
+	return time.Duration(n) * time.Second
+}
+
+// failSpan 记录失败原因并结束 span
+func failSpan(span oteltrace.Span, err error) {
+	var taskErr *TaskError
+	code := ""
+	if errors.As(err, &taskErr) {
+		code = taskErr.Code
+	}
+	trace.Fail(span, err, code)
+	span.End()
+}
+
+// startStream 从连接池取连接并启动合成任务，返回的 bool 表示失败是否发生在复用的连接上
+func (p *AliyunTTS) startStream(ctx context.Context, segments <-chan string, opts SynthesisOptions) (*AudioStream, bool, error) {
+	// 1. 从连接池获取 WebSocket 连接
+	conn, _, err := p.pool.Get(ctx, endpoint())
+	if err != nil {
+		return nil, false, fmt.Errorf("连接 WebSocket 失败: %w", err)
 	}
-	defer closeConnection(conn)
 
 	// 2. 发送 run-task 指令
-	taskID, err := sendRunTaskCmd(ctx, conn)
+	taskID, err := sendRunTaskCmd(ctx, conn.Conn, opts)
 	if err != nil {
-		return nil, fmt.Errorf("发送 run-task 失败: %w", err)
+		conn.Discard()
+		return nil, conn.Reused(), fmt.Errorf("发送 run-task 失败: %w", err)
 	}
 
-	// 3. 启动音频接收器
-	audioChan := make(chan []byte, 1)
-	errorChan := make(chan error, 1)
+	// 3. 启动音频接收器，音频分片到达后立即转发
+	chunks := make(chan []byte, 16)
+	stream := newAudioStream(chunks)
 	taskStarted := make(chan bool, 1)
-
-	// 启动一个goroutine来接收websocket结果
-	go receiveResults(ctx, conn, audioChan, errorChan, taskStarted)
+	// 发送协程出错时会关闭连接，接收协程据此把真正的原因交给调用方，而不是“读取消息失败”
+	sendErrc := make(chan error, 1)
+	senderDone := make(chan struct{})
+
+	go func() {
+		words := newWordCollector()
+		err := receiveResults(ctx, conn.Conn, chunks, taskStarted, words, &stream.characters)
+		stream.words = words.result()
+		if err != nil {
+			select {
+			case sendErr := <-sendErrc:
+				err = sendErr
+			default:
+			}
+		}
+		stream.finish(err)
+		close(chunks)
+	}()
+
+	// 请求取消时立即关闭连接，让阻塞在 ReadMessage 上的接收协程退出；
+	// 任务正常结束且发送协程也已退出时，把连接还给连接池
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stream.done:
+		}
+		// 调用方读完流后通常马上取消 ctx，两个 case 可能同时就绪，以任务是否已经结束为准
+		select {
+		case <-stream.done:
+		default:
+			conn.Discard()
+			return
+		}
+		<-senderDone
+		if stream.err == nil {
+			conn.Release()
+		} else {
+			conn.Discard()
+		}
+	}()
 
 	// 4. 等待 task-started
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		close(senderDone)
+		return nil, false, ctx.Err()
 	case <-taskStarted:
 		// 任务启动成功
 		logrus.WithContext(ctx).Infof("tts任务启动成功")
-	case err := <-errorChan:
-		return nil, err
-	case <-time.After(10 * time.Second):
-		return nil, fmt.Errorf("等待 task-started 超时")
+		span := oteltrace.SpanFromContext(ctx)
+		span.SetAttributes(trace.AttrTaskID.String(taskID))
+		span.AddEvent(trace.EventTaskStarted)
+	case <-stream.done:
+		close(senderDone)
+		// 服务端明确返回 task-failed 说明连接是好的，不需要重试
+		var taskErr *TaskError
+		return nil, conn.Reused() && !errors.As(stream.err, &taskErr), stream.err
+	case <-time.After(seconds(p.conf.TTS.StartTimeoutSeconds, defaultStartTimeout)):
+		close(senderDone)
+		conn.Discard()
+		return nil, false, fmt.Errorf("%w: 等待 task-started 超时", ErrTimeout)
 	}
 
-	// 5. 发送文本内容
-	if err := sendText(ctx, conn, taskID, text); err != nil {
-		logrus.WithContext(ctx).Errorf("发送文本失败 %v", err)
-		return nil, fmt.Errorf("发送文本失败: %w", err)
-	}
+	// 5. 逐段发送文本，segments 关闭后发送 finish-task
+	go func() {
+		defer close(senderDone)
+		if err := sendSegments(ctx, conn.Conn, taskID, segments, opts.SSML); err != nil {
+			logrus.WithContext(ctx).Errorf("发送合成文本失败 %v", err)
+			sendErrc <- err
+			conn.Discard()
+		}
+	}()
 
-	// 6. 发送 finish-task指令
-	if err := sendFinishTaskCmd(ctx, conn, taskID); err != nil {
-		logrus.WithContext(ctx).Errorf("发送finish-task 失败 %v", err)
-		return nil, fmt.Errorf("发送 finish-task 失败: %w", err)
-	}
+	return stream, false, nil
+}
 
-	// 7. 等待音频数据
-	select {
-	case audioData := <-audioChan:
-		return audioData, nil
-	case err := <-errorChan:
-		return nil, err
-	case <-time.After(30 * time.Second):
-		return nil, fmt.Errorf("合成超时")
+// sendSegments 每段文本发送一条 continue-task，全部发送完后发送 finish-task
+// SSML 模式下只允许一段文本
+func sendSegments(ctx context.Context, conn *websocket.Conn, taskID string, segments <-chan string, ssml bool) error {
+	sent := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case text, ok := <-segments:
+			if !ok {
+				if err := sendFinishTaskCmd(ctx, conn, taskID); err != nil {
+					return fmt.Errorf("发送 finish-task 失败: %w", err)
+				}
+				return nil
+			}
+			if text == "" {
+				continue
+			}
+			if ssml && sent > 0 {
+				return ErrSSMLSegmentLimit
+			}
+			if err := sendText(ctx, conn, taskID, text); err != nil {
+				return fmt.Errorf("发送文本失败: %w", err)
+			}
+			sent++
+		}
 	}
 }
 
-// connectWebSocket 建立 WebSocket 连接
-func connectWebSocket() (*websocket.Conn, error) {
-	header := make(http.Header)
-	header.Add("Authorization", fmt.Sprintf("bearer %s", apiKey))
-	header.Add("X-DashScope-DataInspection", "enable")
-
-	conn, _, err := dialer.Dial(wsURL, header)
-	return conn, err
+// endpoint WebSocket 服务地址和鉴权头
+func endpoint() dashscope.Endpoint {
+	return dashscope.NewEndpoint(wsURL, apiKey)
 }
 
 // sendRunTask 发送 run-task 指令
-func sendRunTaskCmd(ctx context.Context, conn *websocket.Conn) (string, error) {
-	runTaskCmd, taskID, err := generateRunTaskCmd()
+func sendRunTaskCmd(ctx context.Context, conn *websocket.Conn, opts SynthesisOptions) (string, error) {
+	runTaskCmd, taskID, err := generateRunTaskCmd(opts)
 	if err != nil {
 		logrus.WithContext(ctx).Warningf("生成tts run-task指令失败 %v", err)
+		return "", err
 	}
 	err = conn.WriteMessage(websocket.TextMessage, []byte(runTaskCmd))
 	return taskID, err
 }
-func generateRunTaskCmd() (string, string, error) {
+func generateRunTaskCmd(opts SynthesisOptions) (string, string, error) {
 	// 生成任务ID
 	taskID := uuid.New().String()
 	// 生成 run-task指令
@@ -166,14 +341,16 @@ func generateRunTaskCmd() (string, string, error) {
 			Model:     ttsModel,
 			Parameters: Params{
 				TextType:   "PlainText",
-				Voice:      "longanyang",
-				Format:     "mp3",
-				SampleRate: 22050,
-				Volume:     50,
-				Rate:       1,
-				Pitch:      1,
+				Voice:      opts.Voice,
+				Format:     opts.Format,
+				SampleRate: opts.SampleRate,
+				Volume:     opts.Volume,
+				Rate:       opts.Rate,
+				Pitch:      opts.Pitch,
 				// 如果enable_ssml设为true，只允许发送一次continue-task指令，否则会报错“Text request limit violated, expected 1.”
-				EnableSsml: false,
+				// sendSegments 会在发送第二段之前返回 ErrSSMLSegmentLimit
+				EnableSsml:           opts.SSML,
+				WordTimestampEnabled: opts.WordTimestamps,
 			},
 			Input: Input{},
 		},
@@ -183,21 +360,32 @@ func generateRunTaskCmd() (string, string, error) {
 	return string(runTaskJSON), taskID, err
 }
 
-// receiveResults 接收 WebSocket 结果
-func receiveResults(ctx context.Context, conn *websocket.Conn, audioChan chan<- []byte, errorChan chan<- error, taskStart chan<- bool) {
-	var audioBuffer bytes.Buffer
-
+// receiveResults 接收 WebSocket 结果，音频分片写入 chunks
+// 任务正常结束返回 nil，计费字符数写入 characters
+func receiveResults(ctx context.Context, conn *websocket.Conn, chunks chan<- []byte, taskStart chan<- bool, words *wordCollector, characters *int) error {
+	span := oteltrace.SpanFromContext(ctx)
+	audioBytes := 0
 	for {
 		messageType, message, err := conn.ReadMessage()
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			logrus.WithContext(ctx).Errorf("读取消息失败: %v", err)
-			errorChan <- fmt.Errorf("读取消息失败: %w", err)
-			return
+			return fmt.Errorf("读取消息失败: %w", err)
 		}
 
 		// 处理二进制消息(音频数据)
 		if messageType == websocket.BinaryMessage {
-			audioBuffer.Write(message)
+			if audioBytes == 0 {
+				span.AddEvent(trace.EventFirstResult)
+			}
+			audioBytes += len(message)
+			select {
+			case chunks <- message:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 			continue
 		}
 
@@ -211,17 +399,29 @@ func receiveResults(ctx context.Context, conn *websocket.Conn, audioChan chan<-
 		case "task-started":
 			taskStart <- true
 
+		case "result-generated":
+			if event.Payload.Output != nil {
+				words.apply(event.Payload.Output.Sentence)
+			}
+
 		case "task-finished":
-			audioChan <- audioBuffer.Bytes()
-			return
+			span.AddEvent(trace.EventTaskFinished)
+			span.SetAttributes(attribute.Int("tts.audio_bytes", audioBytes))
+			if event.Payload.Usage != nil {
+				*characters = event.Payload.Usage.Characters
+			}
+			return nil
 
 		case "task-failed":
 			errorMsg := event.Header.ErrorMessage
 			if errorMsg == "" {
 				errorMsg = "TTS 任务失败"
 			}
-			errorChan <- fmt.Errorf(errorMsg)
-			return
+			return &TaskError{
+				TaskID:  event.Header.TaskID,
+				Code:    event.Header.ErrorCode,
+				Message: errorMsg,
+			}
 		}
 	}
 }
@@ -273,9 +473,8 @@ func generateFinishTaskCmd(taskID string) (string, error) {
 }
 
 // sendFinishTask 发送 finish-task 指令
+// 同一连接上的消息按发送顺序到达，continue-task 写出后即可发送，无需等待
 func sendFinishTaskCmd(ctx context.Context, conn *websocket.Conn, taskID string) error {
-	// 稍微延迟,确保文本已发送
-	time.Sleep(200 * time.Millisecond)
 	finishTaskCmd, err := generateFinishTaskCmd(taskID)
 	if err != nil {
 		logrus.WithContext(ctx).Errorf("生成finish-task指令失败 %v", err)
@@ -283,10 +482,3 @@ func sendFinishTaskCmd(ctx context.Context, conn *websocket.Conn, taskID string)
 	}
 	return conn.WriteMessage(websocket.TextMessage, []byte(finishTaskCmd))
 }
-
-// 关闭连接
-func closeConnection(conn *websocket.Conn) {
-	if conn != nil {
-		conn.Close()
-	}
-}
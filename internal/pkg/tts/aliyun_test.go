@@ -0,0 +1,83 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/dashscope"
+
+	"github.com/gorilla/websocket"
+)
+
+// newFakeTTSServer 按 run-task / finish-task 回复 task-started、一个音频分片和 task-finished，统计建立的连接数
+func newFakeTTSServer(t *testing.T, dials *atomic.Int64) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		dials.Add(1)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var event Event
+			if err := json.Unmarshal(data, &event); err != nil {
+				return
+			}
+			switch event.Header.Action {
+			case "run-task":
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"header":{"event":"task-started"}}`))
+			case "finish-task":
+				_ = conn.WriteMessage(websocket.BinaryMessage, []byte("audio"))
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"header":{"event":"task-finished"}}`))
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// Synthesize 返回时会取消自己的 ctx，正常结束的连接仍然要放回连接池，而不是随机被关闭
+func TestSynthesizeReleasesConnection(t *testing.T) {
+	var dials atomic.Int64
+	url := newFakeTTSServer(t, &dials)
+	pool := dashscope.NewPool(config.WsPoolConfig{})
+	defer pool.Close()
+	svc := NewAliyunTTS(&config.AliyunConfig{
+		DASHSCOPE_API_KEY: "sk",
+		TTS:               config.AliyunTTSConfig{WsURL: url, Model: "m"},
+	}, pool)
+
+	for i := 0; i < 20; i++ {
+		synthesis, err := svc.Synthesize(context.Background(), "Hello.", SynthesisOptions{})
+		if err != nil {
+			t.Fatalf("第 %d 次 Synthesize: %v", i+1, err)
+		}
+		if string(synthesis.Audio) != "audio" {
+			t.Fatalf("audio = %q, want %q", synthesis.Audio, "audio")
+		}
+		// 连接在另一个协程里归还
+		deadline := time.Now().Add(time.Second)
+		for idle, _ := pool.Stats(); idle != 1; idle, _ = pool.Stats() {
+			if time.Now().After(deadline) {
+				t.Fatalf("第 %d 次合成后连接没有放回连接池", i+1)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if n := dials.Load(); n != 1 {
+		t.Errorf("建立了 %d 条连接，want 1", n)
+	}
+}
@@ -0,0 +1,255 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"oktalk/internal/pkg/config"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// Redis 中缓存值的前缀：小音频直接存内容，大音频存本地文件路径
+var (
+	cacheInline = []byte("b:")
+	cacheFile   = []byte("f:")
+)
+
+const (
+	cacheKeyPrefix   = "tts:audio:"
+	cacheWordsSuffix = ":words"
+)
+
+// CachedTTS 带缓存的 TTSService 装饰器
+// 相同的文本 + 音色 + 格式 + 模型只合成一次，之后直接从 Redis（或本地文件）返回。
+// 流式合成按完整文本查缓存，未命中时仍在下层的同一个合成任务中发送多段文本
+type CachedTTS struct {
+	next  TTSService
+	rdb   *redis.Client
+	model string
+	conf  config.TTSCacheConfig
+
+	hits   atomic.Int64
+	misses atomic.Int64
+	errors atomic.Int64
+}
+
+// CacheStats 缓存命中统计
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Errors int64 `json:"errors"`
+}
+
+func NewCachedTTS(next TTSService, rdb *redis.Client, conf *config.AliyunTTSConfig) *CachedTTS {
+	return &CachedTTS{
+		next:  next,
+		rdb:   rdb,
+		model: conf.Model,
+		conf:  conf.Cache,
+	}
+}
+
+// Synthesize 先查缓存，未命中时调用下层服务合成并写入缓存
+func (c *CachedTTS) Synthesize(ctx context.Context, text string, opts SynthesisOptions) (*Synthesis, error) {
+	key := c.cacheKey(text, opts.withDefaults())
+	if synthesis := c.lookup(ctx, key, opts.WordTimestamps); synthesis != nil {
+		return synthesis, nil
+	}
+
+	synthesis, err := c.next.Synthesize(ctx, text, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.store(ctx, key, opts.withDefaults().Format, synthesis)
+	return synthesis, nil
+}
+
+// SynthesizeStream 流式合成也经过缓存
+// 等 segments 关闭拿到完整文本后查缓存（各段以空格拼接，和同一段回复走 Synthesize 时共用缓存），
+// 命中时把缓存的音频作为一个分片返回；未命中时交给下层流式合成，正常结束后把完整音频写入缓存
+func (c *CachedTTS) SynthesizeStream(ctx context.Context, segments <-chan string, opts SynthesisOptions) (*AudioStream, error) {
+	var texts []string
+collect:
+	for {
+		select {
+		case text, ok := <-segments:
+			if !ok {
+				break collect
+			}
+			texts = append(texts, text)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	key := c.cacheKey(strings.Join(texts, " "), opts.withDefaults())
+	if synthesis := c.lookup(ctx, key, opts.WordTimestamps); synthesis != nil {
+		return replayStream(synthesis), nil
+	}
+
+	stream, err := Stream(ctx, c.next, Segments(texts...), opts)
+	if err != nil {
+		return nil, err
+	}
+	return Tee(ctx, stream, func(audio []byte, err error) {
+		// 中途失败或被取消时音频不完整，不缓存
+		if err != nil {
+			return
+		}
+		c.store(context.WithoutCancel(ctx), key, opts.withDefaults().Format, &Synthesis{Audio: audio, Words: stream.Words()})
+	}), nil
+}
+
+// lookup 查缓存并计数，未命中或读取失败时返回 nil
+func (c *CachedTTS) lookup(ctx context.Context, key string, withWords bool) *Synthesis {
+	synthesis, err := c.get(ctx, key, withWords)
+	switch {
+	case err == nil:
+		c.hits.Add(1)
+		logrus.WithContext(ctx).Debugf("TTS 缓存命中: %s", key)
+		return synthesis
+	case !errors.Is(err, redis.Nil):
+		// 缓存故障不影响合成
+		c.errors.Add(1)
+		logrus.WithContext(ctx).Warnf("读取 TTS 缓存失败: %v", err)
+	}
+	c.misses.Add(1)
+	return nil
+}
+
+// store 写入缓存，失败只打日志
+func (c *CachedTTS) store(ctx context.Context, key, format string, synthesis *Synthesis) {
+	if err := c.set(ctx, key, format, synthesis); err != nil {
+		c.errors.Add(1)
+		logrus.WithContext(ctx).Warnf("写入 TTS 缓存失败: %v", err)
+	}
+}
+
+// replayStream 把缓存的合成结果包装成只有一个分片、已经结束的流
+func replayStream(synthesis *Synthesis) *AudioStream {
+	chunks := make(chan []byte, 1)
+	stream := newAudioStream(chunks)
+	chunks <- synthesis.Audio
+	close(chunks)
+	stream.words = synthesis.Words
+	stream.finish(nil)
+	return stream
+}
+
+// Stats 返回缓存命中统计
+func (c *CachedTTS) Stats() CacheStats {
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Errors: c.errors.Load(),
+	}
+}
+
+// cacheKey 对所有影响合成结果的参数取哈希
+func (c *CachedTTS) cacheKey(text string, opts SynthesisOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%d\x00%d\x00%g\x00%g\x00%t\x00%t",
+		c.model, text, opts.Voice, opts.Format, opts.SampleRate, opts.Volume, opts.Rate, opts.Pitch, opts.SSML, opts.WordTimestamps)
+	return cacheKeyPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *CachedTTS) ttl() time.Duration {
+	return time.Duration(c.conf.TTLSeconds) * time.Second
+}
+
+// get 读取缓存，未命中返回 redis.Nil
+// 词级时间戳单独存放在 key:words 下
+func (c *CachedTTS) get(ctx context.Context, key string, withWords bool) (*Synthesis, error) {
+	audio, err := c.getAudio(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	synthesis := &Synthesis{Audio: audio}
+	if !withWords {
+		return synthesis, nil
+	}
+	raw, err := c.rdb.Get(ctx, key+cacheWordsSuffix).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// 音色不支持时间戳时不会写入 words，直接返回音频
+		return synthesis, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &synthesis.Words); err != nil {
+		return nil, redis.Nil
+	}
+	return synthesis, nil
+}
+
+func (c *CachedTTS) getAudio(ctx context.Context, key string) ([]byte, error) {
+	val, err := c.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(val, cacheInline):
+		return val[len(cacheInline):], nil
+	case bytes.HasPrefix(val, cacheFile):
+		audio, err := os.ReadFile(string(val[len(cacheFile):]))
+		if errors.Is(err, os.ErrNotExist) {
+			// 文件已被清理，按未命中处理
+			c.rdb.Del(ctx, key)
+			return nil, redis.Nil
+		}
+		return audio, err
+	default:
+		return nil, redis.Nil
+	}
+}
+
+// set 写入缓存
+// 不超过 max_redis_bytes 的音频直接存 Redis；更大的写到 local_dir，Redis 只存路径；超过 max_item_bytes 的不缓存。
+// 词级时间戳只在音频写入成功后才写，避免留下没有音频的 :words
+func (c *CachedTTS) set(ctx context.Context, key, format string, synthesis *Synthesis) error {
+	stored, err := c.setAudio(ctx, key, format, synthesis.Audio)
+	if err != nil || !stored || len(synthesis.Words) == 0 {
+		return err
+	}
+	words, err := json.Marshal(synthesis.Words)
+	if err != nil {
+		return err
+	}
+	return c.rdb.Set(ctx, key+cacheWordsSuffix, words, c.ttl()).Err()
+}
+
+// setAudio 写入音频，返回是否写入了缓存
+func (c *CachedTTS) setAudio(ctx context.Context, key, format string, audio []byte) (bool, error) {
+	switch {
+	case len(audio) == 0, c.conf.MaxItemBytes > 0 && len(audio) > c.conf.MaxItemBytes:
+		return false, nil
+	case len(audio) <= c.conf.MaxRedisBytes:
+		err := c.rdb.Set(ctx, key, append(append([]byte{}, cacheInline...), audio...), c.ttl()).Err()
+		return err == nil, err
+	case c.conf.LocalDir == "":
+		// 太大又没有配置本地目录，不缓存
+		return false, nil
+	}
+
+	if err := os.MkdirAll(c.conf.LocalDir, os.ModePerm); err != nil {
+		return false, err
+	}
+	path := filepath.Join(c.conf.LocalDir, key[len(cacheKeyPrefix):]+"."+format)
+	if err := os.WriteFile(path, audio, 0o644); err != nil {
+		return false, err
+	}
+	err := c.rdb.Set(ctx, key, append(append([]byte{}, cacheFile...), path...), c.ttl()).Err()
+	return err == nil, err
+}
@@ -0,0 +1,129 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"oktalk/internal/pkg/config"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// streamingStub 只支持流式合成，记录收到的文本段
+type streamingStub struct {
+	segments []string
+}
+
+func (s *streamingStub) Synthesize(ctx context.Context, text string, opts SynthesisOptions) (*Synthesis, error) {
+	return nil, errors.New("不应该逐段调用 Synthesize")
+}
+
+func (s *streamingStub) SynthesizeStream(ctx context.Context, segments <-chan string, opts SynthesisOptions) (*AudioStream, error) {
+	chunks := make(chan []byte, 1)
+	stream := newAudioStream(chunks)
+	go func() {
+		defer close(chunks)
+		for text := range segments {
+			s.segments = append(s.segments, text)
+		}
+		chunks <- []byte("audio")
+		stream.finish(nil)
+	}()
+	return stream, nil
+}
+
+func newTestCache(t *testing.T, next TTSService) (*CachedTTS, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return NewCachedTTS(next, rdb, &config.AliyunTTSConfig{
+		Model: "m",
+		Cache: config.TTSCacheConfig{TTLSeconds: 60, MaxRedisBytes: 1 << 20},
+	}), mr
+}
+
+// waitStored 流结束后才在另一个协程里写缓存，等它写完
+func waitStored(t *testing.T, mr *miniredis.Miniredis) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(mr.Keys()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("流结束后没有写入缓存")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func readAll(t *testing.T, stream *AudioStream) string {
+	t.Helper()
+	var audio []byte
+	for chunk := range stream.Chunks {
+		audio = append(audio, chunk...)
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream.Err: %v", err)
+	}
+	return string(audio)
+}
+
+// 流式合成未命中时走下层的同一个合成任务并写入缓存，第二次相同的回复直接命中
+func TestCachedTTSStreamHitMiss(t *testing.T) {
+	next := &streamingStub{}
+	cached, mr := newTestCache(t, next)
+	ctx := context.Background()
+
+	stream, err := Stream(ctx, cached, Segments("Hello.", "How are you?"), SynthesisOptions{})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if audio := readAll(t, stream); audio != "audio" {
+		t.Errorf("audio = %q, want %q", audio, "audio")
+	}
+	if len(next.segments) != 2 {
+		t.Errorf("下层收到的文本段 = %q, want 2 段", next.segments)
+	}
+	if got := cached.Stats(); got.Hits != 0 || got.Misses != 1 {
+		t.Errorf("第一次 Stats = %+v, want 1 次未命中", got)
+	}
+	waitStored(t, mr)
+
+	stream, err = Stream(ctx, cached, Segments("Hello.", "How are you?"), SynthesisOptions{})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if audio := readAll(t, stream); audio != "audio" {
+		t.Errorf("命中时 audio = %q, want %q", audio, "audio")
+	}
+	if len(next.segments) != 2 {
+		t.Errorf("命中时不应再调用下层，下层收到的文本段 = %q", next.segments)
+	}
+	if got := cached.Stats(); got.Hits != 1 || got.Misses != 1 {
+		t.Errorf("第二次 Stats = %+v, want 1 次命中 1 次未命中", got)
+	}
+}
+
+// 流式合成写入的缓存与同一段回复的 Synthesize 共用
+func TestCachedTTSStreamSharesSyncEntry(t *testing.T) {
+	next := &streamingStub{}
+	cached, mr := newTestCache(t, next)
+	ctx := context.Background()
+
+	stream, err := cached.SynthesizeStream(ctx, Segments("Hello.", "How are you?"), SynthesisOptions{})
+	if err != nil {
+		t.Fatalf("SynthesizeStream: %v", err)
+	}
+	readAll(t, stream)
+	waitStored(t, mr)
+
+	synthesis, err := cached.Synthesize(ctx, "Hello. How are you?", SynthesisOptions{})
+	if err != nil {
+		t.Fatalf("Synthesize 应该命中缓存: %v", err)
+	}
+	if string(synthesis.Audio) != "audio" {
+		t.Errorf("audio = %q, want %q", synthesis.Audio, "audio")
+	}
+}
@@ -0,0 +1,20 @@
+package tts
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTimeout 等待合成任务启动或合成完成超时，调用方使用 errors.Is 判断
+var ErrTimeout = errors.New("tts: 合成超时")
+
+// TaskError 服务端 task-failed 事件携带的错误信息
+type TaskError struct {
+	TaskID  string
+	Code    string // header.error_code
+	Message string // header.error_message
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("tts task %s failed: [%s] %s", e.TaskID, e.Code, e.Message)
+}
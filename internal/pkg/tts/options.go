@@ -0,0 +1,96 @@
+package tts
+
+import (
+	"errors"
+	"fmt"
+
+	"oktalk/internal/pkg/config"
+)
+
+// 默认合成参数，与最初硬编码的取值保持一致
+var defaultOptions = SynthesisOptions{
+	Voice:      "longanyang",
+	Format:     "mp3",
+	SampleRate: 22050,
+	Volume:     50,
+	Rate:       1,
+	Pitch:      1,
+}
+
+// ErrInvalidOptions 合成参数不合法
+var ErrInvalidOptions = errors.New("tts: 合成参数不合法")
+
+var supportedFormats = map[string]bool{"mp3": true, "pcm": true, "wav": true, "opus": true}
+
+var supportedSampleRates = map[int]bool{8000: true, 16000: true, 22050: true, 24000: true, 44100: true, 48000: true}
+
+// withDefaults 用默认值补全未设置的字段
+func (o SynthesisOptions) withDefaults() SynthesisOptions {
+	if o.Voice == "" {
+		o.Voice = defaultOptions.Voice
+	}
+	if o.Format == "" {
+		o.Format = defaultOptions.Format
+	}
+	if o.SampleRate == 0 {
+		o.SampleRate = defaultOptions.SampleRate
+	}
+	if o.Volume == 0 {
+		o.Volume = defaultOptions.Volume
+	}
+	if o.Rate == 0 {
+		o.Rate = defaultOptions.Rate
+	}
+	if o.Pitch == 0 {
+		o.Pitch = defaultOptions.Pitch
+	}
+	return o
+}
+
+// validate 校验参数是否在服务端支持的范围内
+func (o SynthesisOptions) validate() error {
+	if !supportedFormats[o.Format] {
+		return fmt.Errorf("%w: 不支持的音频格式: %s", ErrInvalidOptions, o.Format)
+	}
+	if !supportedSampleRates[o.SampleRate] {
+		return fmt.Errorf("%w: 不支持的采样率: %d", ErrInvalidOptions, o.SampleRate)
+	}
+	if o.Volume < 1 || o.Volume > 100 {
+		return fmt.Errorf("%w: 音量超出范围 [1, 100]: %d", ErrInvalidOptions, o.Volume)
+	}
+	if o.Rate < 0.5 || o.Rate > 2 {
+		return fmt.Errorf("%w: 语速超出范围 [0.5, 2]: %v", ErrInvalidOptions, o.Rate)
+	}
+	if o.Pitch < 0.5 || o.Pitch > 2 {
+		return fmt.Errorf("%w: 语调超出范围 [0.5, 2]: %v", ErrInvalidOptions, o.Pitch)
+	}
+	return nil
+}
+
+// ResolveOptions 从配置的音色目录中查找合成参数
+// name 可以是音色档案名（voices），也可以是场景名（scenarios），都找不到时使用 default_voice
+func ResolveOptions(conf *config.AliyunTTSConfig, name string) SynthesisOptions {
+	if profile, ok := conf.Voices[name]; ok {
+		return fromProfile(profile)
+	}
+	if profileName, ok := conf.Scenarios[name]; ok {
+		if profile, ok := conf.Voices[profileName]; ok {
+			return fromProfile(profile)
+		}
+	}
+	if profile, ok := conf.Voices[conf.DefaultVoice]; ok {
+		return fromProfile(profile)
+	}
+	return defaultOptions
+}
+
+func fromProfile(p config.VoiceProfile) SynthesisOptions {
+	return SynthesisOptions{
+		Voice:      p.Voice,
+		Format:     p.Format,
+		SampleRate: p.SampleRate,
+		Volume:     p.Volume,
+		Rate:       p.Rate,
+		Pitch:      p.Pitch,
+	}.withDefaults()
+}
@@ -0,0 +1,132 @@
+package tts
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrSSMLSegmentLimit SSML 模式下服务端只接受一条 continue-task
+// 对应服务端报错 "Text request limit violated, expected 1."
+var ErrSSMLSegmentLimit = errors.New("tts: SSML 模式只能发送一段文本")
+
+// SSML 链式构造 SSML 文本，所有文本内容都会做 XML 转义
+//
+//	tts.NewSSML().Text("Let's learn").Break(300*time.Millisecond).Slow("apple", 0.7).String()
+type SSML struct {
+	sb strings.Builder
+}
+
+func NewSSML() *SSML {
+	return &SSML{}
+}
+
+// Text 普通文本
+func (b *SSML) Text(text string) *SSML {
+	b.sb.WriteString(escapeXML(text))
+	return b
+}
+
+// Break 停顿
+func (b *SSML) Break(d time.Duration) *SSML {
+	fmt.Fprintf(&b.sb, `<break time="%dms"/>`, d.Milliseconds())
+	return b
+}
+
+// Emphasis 重读，level 取值 strong / moderate / reduced
+func (b *SSML) Emphasis(text, level string) *SSML {
+	fmt.Fprintf(&b.sb, `<emphasis level="%s">%s</emphasis>`, escapeXML(level), escapeXML(text))
+	return b
+}
+
+// Slow 放慢语速朗读，rate 小于 1 表示变慢
+func (b *SSML) Slow(text string, rate float64) *SSML {
+	fmt.Fprintf(&b.sb, `<prosody rate="%.2f">%s</prosody>`, rate, escapeXML(text))
+	return b
+}
+
+// Stress 放慢并重读，用于需要孩子跟读的单词
+func (b *SSML) Stress(text string, rate float64) *SSML {
+	fmt.Fprintf(&b.sb, `<prosody rate="%.2f"><emphasis level="strong">%s</emphasis></prosody>`, rate, escapeXML(text))
+	return b
+}
+
+// Phoneme 指定发音，alphabet 英文用 cmu，中文用 py
+func (b *SSML) Phoneme(text, alphabet, ph string) *SSML {
+	fmt.Fprintf(&b.sb, `<phoneme alphabet="%s" ph="%s">%s</phoneme>`, escapeXML(alphabet), escapeXML(ph), escapeXML(text))
+	return b
+}
+
+// String 输出完整的 <speak> 文档
+func (b *SSML) String() string {
+	return "<speak>" + b.sb.String() + "</speak>"
+}
+
+// FocusWord 本轮要教的词汇
+type FocusWord struct {
+	Word    string
+	Phoneme string // 可选，CMU 音标，如 "ae1 p ah0 l"
+}
+
+// 教学词汇的朗读方式：前后停顿、放慢、重读
+const (
+	focusWordPause = 300 * time.Millisecond
+	focusWordRate  = 0.7
+)
+
+// KidFriendlySSML 把回复文本中出现的教学词汇放慢、重读并在前后留出停顿，方便孩子跟读
+// 没有匹配到任何词汇时 ok 返回 false，调用方按普通文本合成即可
+func KidFriendlySSML(text string, words []FocusWord) (ssml string, ok bool) {
+	if len(words) == 0 {
+		return "", false
+	}
+	byWord := make(map[string]FocusWord, len(words))
+	alternatives := make([]string, 0, len(words))
+	for _, w := range words {
+		word := strings.TrimSpace(w.Word)
+		if word == "" {
+			continue
+		}
+		byWord[strings.ToLower(word)] = w
+		alternatives = append(alternatives, regexp.QuoteMeta(word))
+	}
+	if len(alternatives) == 0 {
+		return "", false
+	}
+	pattern := regexp.MustCompile(`(?i)\b(` + strings.Join(alternatives, "|") + `)\b`)
+	matches := pattern.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	b := NewSSML()
+	last := 0
+	for _, m := range matches {
+		b.Text(text[last:m[0]])
+		matched := text[m[0]:m[1]]
+		b.Break(focusWordPause)
+		if w := byWord[strings.ToLower(matched)]; w.Phoneme != "" {
+			b.Phoneme(matched, "cmu", w.Phoneme)
+		} else {
+			b.Stress(matched, focusWordRate)
+		}
+		b.Break(focusWordPause)
+		last = m[1]
+	}
+	b.Text(text[last:])
+	return b.String(), true
+}
+
+var xmlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+	"'", "&apos;",
+)
+
+func escapeXML(s string) string {
+	return xmlEscaper.Replace(s)
+}
@@ -0,0 +1,194 @@
+package tts
+
+import (
+	"context"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// StreamingTTSService 边合成边输出音频的 TTS 服务
+type StreamingTTSService interface {
+	// SynthesizeStream 把 segments 中的每段文本依次作为一条 continue-task 发送到同一个合成任务，
+	// segments 关闭后结束任务。音频分片到达后立即通过 AudioStream 返回。
+	// 调用方需要读完 AudioStream 或取消 ctx，否则接收协程会一直阻塞
+	SynthesizeStream(ctx context.Context, segments <-chan string, opts SynthesisOptions) (*AudioStream, error)
+}
+
+// AudioStream 流式合成的音频输出
+// 可以直接 range Chunks，也可以当作 io.Reader 使用，两种方式不要混用
+type AudioStream struct {
+	Chunks <-chan []byte
+
+	done       chan struct{}
+	err        error
+	words      []WordTiming
+	characters int
+	buf        []byte
+}
+
+func newAudioStream(chunks <-chan []byte) *AudioStream {
+	return &AudioStream{Chunks: chunks, done: make(chan struct{})}
+}
+
+// finish 记录合成结束的原因，必须在关闭 Chunks 之前调用且只调用一次
+func (s *AudioStream) finish(err error) {
+	s.err = err
+	close(s.done)
+}
+
+// Err 阻塞直到合成结束，返回合成过程中遇到的错误，正常结束时返回 nil
+func (s *AudioStream) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Words 阻塞直到合成结束，返回词级时间戳（需开启 WordTimestamps）
+func (s *AudioStream) Words() []WordTiming {
+	<-s.done
+	return s.words
+}
+
+// Characters 阻塞直到合成结束，返回服务端计费的字符数
+func (s *AudioStream) Characters() int {
+	<-s.done
+	return s.characters
+}
+
+// Read 实现 io.Reader，合成正常结束时返回 io.EOF
+func (s *AudioStream) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		chunk, ok := <-s.Chunks
+		if !ok {
+			if err := s.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		s.buf = chunk
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// Stream 流式合成
+// svc 支持流式时直接调用 SynthesizeStream；否则逐段调用 Synthesize，每段合成完成后作为一个分片输出，
+// 这样经过缓存、熔断等装饰器包装后的服务也能用同一套调用方式
+func Stream(ctx context.Context, svc TTSService, segments <-chan string, opts SynthesisOptions) (*AudioStream, error) {
+	if streaming, ok := svc.(StreamingTTSService); ok {
+		return streaming.SynthesizeStream(ctx, segments, opts)
+	}
+
+	chunks := make(chan []byte, 1)
+	stream := newAudioStream(chunks)
+	go func() {
+		defer close(chunks)
+		// 逐段合成时每段的时间戳都从 0 开始，按上一段最后一个词的结束时间顺延（不含段尾静音，是近似值）
+		var (
+			offset    int64
+			sentences int
+		)
+		for text := range segments {
+			synthesis, err := svc.Synthesize(ctx, text, opts)
+			if err != nil {
+				stream.finish(err)
+				return
+			}
+			if n := len(synthesis.Words); n > 0 {
+				stream.words = append(stream.words, shiftWords(synthesis.Words, offset, sentences)...)
+				offset += synthesis.Words[n-1].EndTime
+				sentences += synthesis.Words[n-1].Sentence + 1
+			}
+			stream.characters += synthesis.Characters
+			select {
+			case chunks <- synthesis.Audio:
+			case <-ctx.Done():
+				stream.finish(ctx.Err())
+				return
+			}
+		}
+		stream.finish(nil)
+	}()
+	return stream, nil
+}
+
+// Tee 返回一个转发 s 全部分片的新流，同时保留完整音频
+// 新流结束后调用 onDone，传入完整音频和合成结果（中途失败时 audio 只有已经输出的部分）。
+// ctx 结束时停止转发并丢弃剩余分片，避免读取方提前退出后转发协程一直阻塞
+func Tee(ctx context.Context, s *AudioStream, onDone func(audio []byte, err error)) *AudioStream {
+	chunks := make(chan []byte, 1)
+	out := newAudioStream(chunks)
+	go func() {
+		defer close(chunks)
+		var (
+			audio []byte
+			err   error
+		)
+	forward:
+		for chunk := range s.Chunks {
+			audio = append(audio, chunk...)
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				err = ctx.Err()
+				for range s.Chunks {
+				}
+				break forward
+			}
+		}
+		if sErr := s.Err(); sErr != nil {
+			err = sErr
+		}
+		out.words = s.Words()
+		out.characters = s.Characters()
+		out.finish(err)
+		onDone(audio, err)
+	}()
+	return out
+}
+
+// SplitSentences 按句末标点切分长文本，便于流式合成时尽早发送第一句
+func SplitSentences(text string) []string {
+	var (
+		sentences []string
+		current   strings.Builder
+	)
+	runes := []rune(text)
+	for i, r := range runes {
+		current.WriteRune(r)
+		if !isSentenceEnd(r) {
+			continue
+		}
+		// 英文句号后面紧跟非空白字符时（如 3.14、Mr.Smith）不切分
+		if r == '.' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			continue
+		}
+		if s := strings.TrimSpace(current.String()); s != "" {
+			sentences = append(sentences, s)
+		}
+		current.Reset()
+	}
+	if s := strings.TrimSpace(current.String()); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '.', '!', '?', '。', '！', '？', '\n':
+		return true
+	}
+	return false
+}
+
+// Segments 把切好的文本放进一个已关闭的 channel，作为 SynthesizeStream 的输入
+func Segments(texts ...string) <-chan string {
+	ch := make(chan string, len(texts))
+	for _, t := range texts {
+		ch <- t
+	}
+	close(ch)
+	return ch
+}
@@ -0,0 +1,119 @@
+package tts
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 单条字幕最多包含的词数，避免一整句话挤在一行
+const maxCueWords = 12
+
+// Cue 一条字幕
+type Cue struct {
+	BeginTime int64 // 毫秒
+	EndTime   int64 // 毫秒
+	Words     []WordTiming
+}
+
+// Text 字幕文本
+func (c Cue) Text() string {
+	var sb strings.Builder
+	for _, w := range c.Words {
+		sb.WriteString(wordSeparator(sb.String(), w.Text))
+		sb.WriteString(w.Text)
+	}
+	return sb.String()
+}
+
+// BuildCues 按句子切分字幕，一句话过长时再按 maxCueWords 切分
+func BuildCues(words []WordTiming) []Cue {
+	var cues []Cue
+	for _, w := range words {
+		n := len(cues)
+		if n == 0 || cues[n-1].Words[0].Sentence != w.Sentence || len(cues[n-1].Words) >= maxCueWords {
+			cues = append(cues, Cue{BeginTime: w.BeginTime})
+			n++
+		}
+		cues[n-1].Words = append(cues[n-1].Words, w)
+		cues[n-1].EndTime = w.EndTime
+	}
+	return cues
+}
+
+// WebVTT 生成 WebVTT 字幕
+// 每个词前带有行内时间戳（<00:00:01.200>），客户端可据此逐词高亮，实现卡拉 OK 效果
+func WebVTT(words []WordTiming) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n\n")
+	for i, cue := range BuildCues(words) {
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n", i+1, formatTimestamp(cue.BeginTime, '.'), formatTimestamp(cue.EndTime, '.'))
+		var line strings.Builder
+		for j, w := range cue.Words {
+			line.WriteString(wordSeparator(line.String(), w.Text))
+			if j > 0 {
+				fmt.Fprintf(&line, "<%s>", formatTimestamp(w.BeginTime, '.'))
+			}
+			line.WriteString(escapeVTT(w.Text))
+		}
+		sb.WriteString(line.String())
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+// SRT 生成 SRT 字幕（SRT 不支持逐词时间，只按句输出）
+func SRT(words []WordTiming) string {
+	var sb strings.Builder
+	for i, cue := range BuildCues(words) {
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(cue.BeginTime, ','), formatTimestamp(cue.EndTime, ','), cue.Text())
+	}
+	return sb.String()
+}
+
+// formatTimestamp 毫秒转为 hh:mm:ss.mmm（SRT 使用逗号作为毫秒分隔符）
+func formatTimestamp(ms int64, sep byte) string {
+	h := ms / 3600000
+	m := ms / 60000 % 60
+	s := ms / 1000 % 60
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", h, m, s, sep, ms%1000)
+}
+
+// wordSeparator 英文单词之间补空格，中文和标点直接拼接
+func wordSeparator(prev, next string) string {
+	if prev == "" || next == "" {
+		return ""
+	}
+	last, _ := utf8.DecodeLastRuneInString(prev)
+	first, _ := utf8.DecodeRuneInString(next)
+	if isASCIIWordRune(last) && isASCIIWordRune(first) {
+		return " "
+	}
+	// 英文标点之后接单词
+	if strings.ContainsRune(",.!?;:", last) && isASCIIWordRune(first) {
+		return " "
+	}
+	return ""
+}
+
+func isASCIIWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '\'')
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeVTT(s string) string {
+	return vttEscaper.Replace(s)
+}
+
+// Subtitles 按格式生成字幕，format 取值 vtt / srt
+func Subtitles(format string, words []WordTiming) (string, error) {
+	switch format {
+	case "vtt":
+		return WebVTT(words), nil
+	case "srt":
+		return SRT(words), nil
+	default:
+		return "", fmt.Errorf("不支持的字幕格式: %s", format)
+	}
+}
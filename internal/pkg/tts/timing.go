@@ -0,0 +1,54 @@
+package tts
+
+import "sort"
+
+// wordCollector 汇总 result-generated 事件中的词级时间戳
+// 同一句话会多次推送，按 (句子序号, 字符偏移) 去重，后到的覆盖先到的
+type wordCollector struct {
+	words map[[2]int]WordTiming
+}
+
+func newWordCollector() *wordCollector {
+	return &wordCollector{words: make(map[[2]int]WordTiming)}
+}
+
+func (w *wordCollector) apply(s OutputSentence) {
+	for _, word := range s.Words {
+		w.words[[2]int{s.Index, word.BeginIndex}] = WordTiming{
+			Text:      word.Text,
+			BeginTime: word.BeginTime,
+			EndTime:   word.EndTime,
+			Sentence:  s.Index,
+		}
+	}
+}
+
+// result 按句子序号、开始时间排序
+func (w *wordCollector) result() []WordTiming {
+	if len(w.words) == 0 {
+		return nil
+	}
+	words := make([]WordTiming, 0, len(w.words))
+	for _, word := range w.words {
+		words = append(words, word)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if words[i].Sentence != words[j].Sentence {
+			return words[i].Sentence < words[j].Sentence
+		}
+		return words[i].BeginTime < words[j].BeginTime
+	})
+	return words
+}
+
+// shiftWords 把后一段合成结果的时间戳平移到前一段之后，句子序号顺延
+func shiftWords(words []WordTiming, offset int64, sentenceOffset int) []WordTiming {
+	shifted := make([]WordTiming, len(words))
+	for i, w := range words {
+		w.BeginTime += offset
+		w.EndTime += offset
+		w.Sentence += sentenceOffset
+		shifted[i] = w
+	}
+	return shifted
+}
@@ -2,7 +2,36 @@ package tts
 
 import "context"
 
+// SynthesisOptions 语音合成参数，零值字段使用默认值
+type SynthesisOptions struct {
+	Voice      string  // 音色，如 longanyang
+	Format     string  // 音频格式：mp3 / pcm / wav / opus
+	SampleRate int     // 采样率（Hz）
+	Volume     int     // 音量 1-100，0 表示默认音量 50（静音没有意义）
+	Rate       float64 // 语速 0.5-2.0，1 为正常语速
+	Pitch      float64 // 语调 0.5-2.0，1 为正常语调
+	SSML       bool    // 文本为 SSML（见 SSML / KidFriendlySSML），此时一个任务只能发送一段文本
+	// WordTimestamps 返回每个词的开始/结束时间，用于跟读高亮和字幕
+	WordTimestamps bool
+}
+
+// WordTiming 一个词在合成音频中的时间位置
+type WordTiming struct {
+	Text      string `json:"text"`
+	BeginTime int64  `json:"begin_time"` // 毫秒
+	EndTime   int64  `json:"end_time"`   // 毫秒
+	Sentence  int    `json:"sentence"`   // 所在句子的序号，用于切分字幕
+}
+
+// Synthesis 合成结果
+type Synthesis struct {
+	Audio []byte
+	Words []WordTiming // 仅在 WordTimestamps 开启且音色支持时返回
+	// Characters 服务端计费的字符数，命中缓存时为 0
+	Characters int
+}
+
 type TTSService interface {
-	// Synthesize 输入文本，输出生成的音频文件路径
-	Synthesize(ctx context.Context, text string) ([]byte, error)
+	// Synthesize 输入文本，输出生成的音频数据
+	Synthesize(ctx context.Context, text string, opts SynthesisOptions) (*Synthesis, error)
 }
@@ -0,0 +1,19 @@
+package router
+
+import (
+	"oktalk/internal/controller"
+	"oktalk/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRouter 注册管理接口路由，所有接口都需要 X-Admin-Token
+func RegisterAdminRouter(v1 *gin.RouterGroup, handler *controller.AdminHandler, token string) {
+	admin := v1.Group("/admin", middleware.AdminAuth(token))
+	{
+		admin.GET("/usage/summary", handler.UsageSummary)        // 按天 / 用户 / 服务汇总用量和成本
+		admin.GET("/storage/cleanup", handler.StorageCleanup)    // 过期音频清理回收的空间
+		admin.POST("/parents", handler.CreateParent)             // 新建家长账号，返回家长令牌
+		admin.POST("/parents/:id/children", handler.AddChildren) // 给家长绑定孩子
+	}
+}
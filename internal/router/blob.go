@@ -0,0 +1,12 @@
+package router
+
+import (
+	"oktalk/internal/controller"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterBlobRouter 注册本地存储的下载路由，链接自带签名，不需要其他鉴权
+func RegisterBlobRouter(v1 *gin.RouterGroup, handler *controller.BlobHandler) {
+	v1.GET("/blobs/*key", handler.Download)
+}
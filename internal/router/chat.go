@@ -6,10 +6,11 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// RegisterChatRouter 注册聊天模块路由
-func RegisterChatRouter(v1 *gin.RouterGroup, handler *controller.ChatHandler) {
-	chat := v1.Group("/chat")
+// RegisterChatRouter 注册聊天模块路由，middlewares 作用于所有聊天接口（如限流）
+func RegisterChatRouter(v1 *gin.RouterGroup, handler *controller.ChatHandler, middlewares ...gin.HandlerFunc) {
+	chat := v1.Group("/chat", middlewares...)
 	{
-		chat.POST("/voice", handler.VoiceChat) // 映射到结构体方法
+		chat.POST("/voice", handler.VoiceChat)              // 映射到结构体方法
+		chat.POST("/voice/stream", handler.VoiceChatStream) // 回复语音通过 SSE 流式返回
 	}
 }
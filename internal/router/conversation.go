@@ -0,0 +1,18 @@
+package router
+
+import (
+	"oktalk/internal/controller"
+	"oktalk/internal/middleware"
+	"oktalk/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterConversationRouter 注册对话记录路由，供家长查看和回放，需要家长令牌（或管理员令牌）
+func RegisterConversationRouter(v1 *gin.RouterGroup, handler *controller.ConversationHandler, parents *service.ParentService, adminToken string) {
+	conversations := v1.Group("/conversations", middleware.ParentAuth(parents, adminToken))
+	{
+		conversations.GET("", handler.ListSessions)   // 会话列表
+		conversations.GET("/:id", handler.GetSession) // 回放一个会话
+	}
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"oktalk/internal/controller"
+	"oktalk/internal/middleware"
+	"oktalk/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterParentalRouter 注册家长控制路由
+// 孩子只能用 X-User-ID 查看自己的设置；修改设置需要家长令牌（或管理员令牌），孩子由路径指定
+func RegisterParentalRouter(v1 *gin.RouterGroup, handler *controller.ParentalHandler, parents *service.ParentService, adminToken string) {
+	parental := v1.Group("/parental")
+	{
+		parental.GET("/controls", handler.GetControls) // 孩子查询每日时长上限、允许时段、休息提醒
+	}
+	children := parental.Group("/children/:user_id", middleware.ParentAuth(parents, adminToken))
+	{
+		children.GET("/controls", handler.GetChildControls) // 家长查询孩子的设置
+		children.PUT("/controls", handler.UpdateControls)   // 家长修改孩子的设置
+	}
+}
@@ -3,10 +3,14 @@ package router
 import (
 	"oktalk/internal/controller"
 	"oktalk/internal/middleware"
+	"oktalk/internal/pkg/blob"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/ratelimit"
 	"oktalk/internal/pkg/response"
 	"oktalk/internal/service"
 	"oktalk/internal/servicecontext"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	"github.com/gin-gonic/gin"
@@ -17,27 +21,49 @@ func InitRouter(svcctx *servicecontext.ServiceContext) *gin.Engine {
 	gin.SetMode(svcctx.Config.Server.Mode)
 
 	r := gin.New()
+	// 超过该大小的上传文件由 net/http 暂存到磁盘，与内存中直接识别的上限保持一致
+	if limit := svcctx.Config.Upload.MemoryLimitBytes; limit > 0 {
+		r.MaxMultipartMemory = limit
+	}
 
 	// 1. 挂载中间件
 	r.Use(otelgin.Middleware(svcctx.Config.Server.ServerName))
 	r.Use(middleware.TracingMiddleware())
+	r.Use(middleware.Metrics())            // Prometheus 请求指标（放在 Recovery 外层，panic 的请求也会记录）
 	r.Use(middleware.RecoveryMiddleware()) // 防止程序崩溃
+	r.Use(middleware.ErrorResponder())     // c.Error 记录的错误统一转换为错误响应
 	r.Use(middleware.Cors())               // 跨域处理
+	r.Use(middleware.UserIdentity())       // 识别当前孩子（用量统计、额度、家长控制）
 
 	// 2. 初始化所有handler
-	chatHandler := controller.NewChatHandler(service.NewChatService(svcctx))
+	chatHandler := controller.NewChatHandler(service.NewChatService(svcctx), svcctx.Config.Storage.TempDir, svcctx.Config.Upload)
+	parentService := service.NewParentService(svcctx)
+	parentalHandler := controller.NewParentalHandler(service.NewParentalService(svcctx), parentService)
+	adminHandler := controller.NewAdminHandler(service.NewUsageService(svcctx), parentService, svcctx.Janitor)
+	conversationHandler := controller.NewConversationHandler(service.NewConversationService(svcctx), parentService)
+	healthHandler := controller.NewHealthHandler(svcctx.Health, svcctx.Ready)
 
 	// 3. 基础路由
 	r.GET("/ping", func(c *gin.Context) {
-		response.SendJSON(c, 200, nil, "pong")
+		response.SendJSON(c, errcode.CodeSuccess, nil, "pong")
 
 	})
+	r.GET("/healthz", healthHandler.Healthz)         // 存活检查
+	r.GET("/metrics", gin.WrapH(promhttp.Handler())) // Prometheus 抓取
+	r.GET("/readyz", healthHandler.Readyz)           // 就绪检查：退出时或 MySQL / Redis 不可用时返回 503
 
 	// 4.业务路由分组挂载
 	apiV1 := r.Group("/api/v1")
 	{
 		// 调用各模块的注册函数，传入对应的 Handler
-		RegisterChatRouter(apiV1, chatHandler)
+		RegisterChatRouter(apiV1, chatHandler, middleware.RateLimit(ratelimit.NewLimiter(svcctx.Redis), svcctx.Config.RateLimit))
+		RegisterParentalRouter(apiV1, parentalHandler, parentService, svcctx.Config.Server.AdminToken)
+		RegisterConversationRouter(apiV1, conversationHandler, parentService, svcctx.Config.Server.AdminToken)
+		// 本地存储的签名链接指向服务自己的下载接口
+		if local, ok := svcctx.Blob.(*blob.LocalStore); ok {
+			RegisterBlobRouter(apiV1, controller.NewBlobHandler(local))
+		}
+		RegisterAdminRouter(apiV1, adminHandler, svcctx.Config.Server.AdminToken)
 		//RegisterEvalRouter(apiV1, evalHandler)
 		//RegisterReportRouter(apiV1, reportHandler)
 	}
@@ -1,11 +1,19 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"oktalk/internal/model"
 	"oktalk/internal/pkg/asr"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/janitor"
 	"oktalk/internal/pkg/llm"
+	"oktalk/internal/pkg/metrics"
+	"oktalk/internal/pkg/resilience"
 	"oktalk/internal/pkg/tts"
 	"oktalk/internal/servicecontext"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -15,41 +23,399 @@ type ChatService struct {
 	asrService asr.ASRService
 	llmService llm.LLMService
 	ttsService tts.TTSService
+	usage      *UsageService
+	quota      *QuotaService
+	parental   *ParentalService
+	// conversation 保存对话记录
+	conversation *ConversationService
 }
 
 func NewChatService(svcctx *servicecontext.ServiceContext) *ChatService {
+	conf := svcctx.Config
+
+	// 每个 AI 服务都包一层超时、重试、熔断
+	asrService := resilience.NewASR(
+		asr.NewAliyunASR(&conf.Aliyun, svcctx.DashScope),
+		resilience.NewExecutor("asr", conf.Resilience.ASR, resilience.ClassifyASR),
+	)
+	// 模型链中每个模型有独立的熔断器，某个模型故障时直接跳到下一个
+	var links []llm.ChainLink
+	for _, model := range conf.Aliyun.LLM.ModelChain() {
+		links = append(links, llm.ChainLink{
+			Model: model.Model,
+			Service: resilience.NewLLM(
+				llm.NewQwenLLM(&conf.Aliyun, model),
+				resilience.NewExecutor("llm:"+model.Model, conf.Resilience.LLM, resilience.ClassifyLLM),
+			),
+			LatencyBudget: time.Duration(model.LatencyBudgetMs) * time.Millisecond,
+		})
+	}
+	llmService := llm.NewChainLLM(links...)
+	// 缓存放在最外层：服务熔断时已缓存的语音仍然可以播放；流式合成穿过缓存直接交给熔断层
+	var ttsService tts.TTSService = resilience.NewTTS(
+		tts.NewAliyunTTS(&conf.Aliyun, svcctx.DashScope),
+		resilience.NewExecutor("tts", conf.Resilience.TTS, resilience.ClassifyTTS),
+	)
+	if conf.Aliyun.TTS.Cache.Enabled {
+		cached := tts.NewCachedTTS(ttsService, svcctx.Redis, &conf.Aliyun.TTS)
+		metrics.RegisterTTSCache(cached)
+		ttsService = cached
+	}
+
 	return &ChatService{
-		asrService: asr.NewAliyunASR(&svcctx.Config.Aliyun),
-		llmService: llm.NewQwenLLM(&svcctx.Config.Aliyun),
-		ttsService: tts.NewAliyunTTS(&svcctx.Config.Aliyun),
+		svcctx:     svcctx,
+		asrService: asrService,
+		llmService: llmService,
+		ttsService: ttsService,
+		usage:      NewUsageService(svcctx),
+		quota:      NewQuotaService(svcctx),
+		parental:   NewParentalService(svcctx),
+
+		conversation: NewConversationService(svcctx),
 	}
 }
 
+// VoiceChatRequest 一轮语音对话的输入
+type VoiceChatRequest struct {
+	UserID    uint   // 当前孩子的用户 ID，用于用量统计和每日额度
+	ClientIP  string // 匿名请求按 IP 统计每日额度
+	SessionID uint   // 所属会话，为 0 时开始新会话
+	AudioPath string // 上传音频的临时文件，本轮结束（流式回复在语音流结束）后由 ChatService 删除
+	AudioData []byte // 较小的上传直接放在内存中，不为空时不使用 AudioPath
+	Voice     string // 音色档案名或场景名，为空时使用配置的默认音色
+	// FocusWords 本轮正在教的词汇，回复中出现时会放慢、重读（SSML 模式）
+	FocusWords []tts.FocusWord
+	// Subtitle 需要的字幕格式（vtt / srt），为空时不请求词级时间戳
+	Subtitle string
+}
+
+// VoiceChatResult 一轮语音对话的结果
+type VoiceChatResult struct {
+	UserText    string `json:"user_text"`              // 孩子说的话（ASR 结果）
+	ReplyText   string `json:"reply_text"`             // AI 老师的回复
+	ReplyAudio  []byte `json:"reply_audio,omitempty"`  // 回复的语音（JSON 中为 base64），合成失败时为空
+	AudioFormat string `json:"audio_format,omitempty"` // 回复语音的格式
+	Model       string `json:"model,omitempty"`        // 实际生成回复的模型
+	Silent      bool   `json:"silent"`                 // 没有识别到有效语音
+	// Blocked 家长设置的限制生效，本轮没有进行对话，ReplyText 是对孩子说的提示：
+	// daily_limit 今日时长已用完 / quiet_hours 不在允许使用的时间段
+	Blocked string `json:"blocked,omitempty"`
+	// Reminder 休息提醒，已经加在 ReplyText 末尾
+	Reminder string `json:"reminder,omitempty"`
+	// Words 回复语音的词级时间戳，Subtitles 为按请求格式生成的字幕，用于跟读高亮
+	Words     []tts.WordTiming `json:"words,omitempty"`
+	Subtitles string           `json:"subtitles,omitempty"`
+	// SessionID 本轮所属的会话，下一轮带上它继续同一个会话；匿名请求不保存对话，为 0
+	SessionID uint `json:"session_id,omitempty"`
+
+	timings turnTimings
+}
+
+// turnTimings 一轮对话各阶段的耗时
+type turnTimings struct {
+	asr, llm time.Duration
+}
+
 // ProcessVoiceChat 核心串联逻辑
-func (s *ChatService) ProcessVoiceChat(ctx context.Context, audioPath string) (string, error) {
+// 返回的 error 均为 *errcode.AppError，携带可直接返回给客户端的业务码
+func (s *ChatService) ProcessVoiceChat(ctx context.Context, req *VoiceChatRequest) (result *VoiceChatResult, err error) {
+	start := time.Now()
+	defer s.removeUpload(ctx, req.AudioPath)
+	defer func() {
+		metrics.ObserveTurn(metrics.ModeSync, turnOutcome(result, err), time.Since(start))
+	}()
+	session, err := s.session(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	result, err = s.recognizeAndReply(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	session = s.startSession(ctx, req, session)
+	result.SessionID = sessionID(session)
+
+	// 3. TTS: 回复文本转语音
+	ttsStart := time.Now()
+	s.synthesizeReply(ctx, req, result)
+	s.saveTurn(ctx, req, session, result, result.ReplyAudio, time.Since(ttsStart), time.Since(start))
+	return result, nil
+}
+
+// StreamVoiceChat 与 ProcessVoiceChat 相同，但回复语音以流的形式返回
+// 回复按句切分后在同一个合成任务里依次发送，第一句合成出来就可以开始播放。
+// 语音合成启动失败时返回的 stream 为 nil，降级为纯文本回复
+func (s *ChatService) StreamVoiceChat(ctx context.Context, req *VoiceChatRequest) (*VoiceChatResult, *tts.AudioStream, error) {
+	start := time.Now()
+	// 返回语音流时，临时文件要等语音流结束、对话记录保存之后再删除
+	streaming := false
+	defer func() {
+		if !streaming {
+			s.removeUpload(ctx, req.AudioPath)
+		}
+	}()
+	session, err := s.session(ctx, req)
+	if err != nil {
+		metrics.ObserveTurn(metrics.ModeStream, metrics.OutcomeError, time.Since(start))
+		return nil, nil, err
+	}
+	result, err := s.recognizeAndReply(ctx, req)
+	if err != nil {
+		metrics.ObserveTurn(metrics.ModeStream, metrics.OutcomeError, time.Since(start))
+		return nil, nil, err
+	}
+	session = s.startSession(ctx, req, session)
+	result.SessionID = sessionID(session)
+
+	text, opts := s.replyOptions(req, result.ReplyText)
+	// SSML 模式下服务端只接受一段文本，不能按句切分
+	segments := []string{text}
+	if !opts.SSML {
+		segments = tts.SplitSentences(text)
+	}
+	ttsStart := time.Now()
+	stream, err := tts.Stream(ctx, s.ttsService, tts.Segments(segments...), opts)
+	if err != nil {
+		logrus.WithContext(ctx).Warnf("TTS stream error, 降级为纯文本回复: %v", err)
+		metrics.ObserveTTS(metrics.ModeStream, time.Since(ttsStart), 0, err)
+		s.saveTurn(ctx, req, session, result, nil, time.Since(ttsStart), time.Since(start))
+		metrics.ObserveTurn(metrics.ModeStream, turnOutcome(result, nil), time.Since(start))
+		return result, nil, nil
+	}
+	result.AudioFormat = opts.Format
+	// 合成结束（包括中途断开）后才知道计费字符数和完整的回复语音
+	synthesized := stream
+	taskDone := s.svcctx.BeginTask()
+	stream = tts.Tee(ctx, synthesized, func(audio []byte, err error) {
+		defer taskDone()
+		metrics.ObserveTTS(metrics.ModeStream, time.Since(ttsStart), len(audio), err)
+		metrics.ObserveTurn(metrics.ModeStream, turnOutcome(result, nil), time.Since(start))
+		s.usage.RecordTTS(ctx, req.UserID, s.svcctx.Config.Aliyun.TTS.Model, synthesized.Characters())
+		if err != nil {
+			audio = nil
+		}
+		s.saveTurn(ctx, req, session, result, audio, time.Since(ttsStart), time.Since(start))
+		s.removeUpload(ctx, req.AudioPath)
+	})
+	streaming = true
+	return result, stream, nil
+}
+
+// recognizeAndReply ASR + LLM，得到孩子说的话和 AI 老师的回复文本
+func (s *ChatService) recognizeAndReply(ctx context.Context, req *VoiceChatRequest) (*VoiceChatResult, error) {
+	// 家长限制优先检查，被拒绝时不调用任何 AI 服务，只把提示语合成语音
+	verdict := s.parental.Admit(ctx, req.UserID, time.Now())
+	if verdict.Blocked != "" {
+		logrus.WithContext(ctx).Infof("用户 %d 受家长限制（%s），本轮不进行对话", req.UserID, verdict.Blocked)
+		return &VoiceChatResult{ReplyText: verdict.Message, Blocked: verdict.Blocked}, nil
+	}
+	if err := s.quota.Check(ctx, req.UserID, req.ClientIP); err != nil {
+		return nil, err
+	}
+
 	// 1. ASR: 语音转文字
-	// 注意：这里的 RecognizeOnce 需要实现你提供的示例代码中的 WebSocket 逻辑
-	recognizedText, err := s.asrService.RecognizeOnce(ctx, audioPath)
+	asrStart := time.Now()
+	recognized, err := s.recognize(ctx, req)
+	asrElapsed := time.Since(asrStart)
 	if err != nil {
-		logrus.Errorf("ASR error: %v", err)
-		return "", err
+		logrus.WithContext(ctx).Errorf("ASR error: %v", err)
+		appErr := asrError(err)
+		metrics.ObserveASR(asrElapsed, appErr)
+		return nil, appErr
 	}
+	metrics.ObserveASR(asrElapsed, nil)
+	seconds := billedSeconds(recognized)
+	s.usage.RecordASR(ctx, req.UserID, s.svcctx.Config.Aliyun.ASR.Model, seconds)
+	s.quota.Consume(ctx, req.UserID, req.ClientIP, seconds)
+	reminder := s.parental.Track(ctx, req.UserID, verdict, seconds, time.Now())
 
-	if recognizedText == "" {
-		return "Sorry, I didn't hear anything clearly.", nil
+	// 识别成功但没有内容，说明孩子没有说话，和服务故障区分开
+	if recognized.Text == "" {
+		return &VoiceChatResult{
+			ReplyText: "Sorry, I didn't hear anything clearly.",
+			Silent:    true,
+			timings:   turnTimings{asr: asrElapsed},
+		}, nil
 	}
 
-	logrus.Infof("🎙️ ASR Result: %s", recognizedText)
+	logrus.WithContext(ctx).Infof("🎙️ ASR Result: %s", recognized.Text)
 
 	// 2. LLM: 生成回复文本
-	replyText, err := s.llmService.Chat(ctx, recognizedText)
+	llmStart := time.Now()
+	reply, err := s.llmService.Chat(ctx, recognized.Text)
+	llmElapsed := time.Since(llmStart)
+	if err != nil {
+		logrus.WithContext(ctx).Errorf("LLM error: %v", err)
+		appErr := llmError(err)
+		metrics.ObserveLLM(llmElapsed, "", 0, 0, appErr)
+		return nil, appErr
+	}
+	metrics.ObserveLLM(llmElapsed, reply.Model, reply.PromptTokens, reply.CompletionTokens, nil)
+	s.usage.RecordLLM(ctx, req.UserID, reply)
+
+	logrus.WithContext(ctx).Infof("🤖 AI Reply (%s): %s", reply.Model, reply.Content)
+
+	result := &VoiceChatResult{
+		UserText:  recognized.Text,
+		ReplyText: reply.Content,
+		Model:     reply.Model,
+		timings:   turnTimings{asr: asrElapsed, llm: llmElapsed},
+	}
+	if reminder != "" {
+		result.ReplyText += " " + reminder
+		result.Reminder = reminder
+	}
+	return result, nil
+}
+
+// session 校验客户端带来的会话，匿名请求和新会话返回 nil
+// 新会话不在这里创建：家长限制、额度、识别等检查都可能拒绝本轮，等确定要保存对话时再由 startSession 创建。
+// 查询会话失败时只打日志，本轮对话照常进行但不保存
+func (s *ChatService) session(ctx context.Context, req *VoiceChatRequest) (*model.ConversationSession, error) {
+	if req.UserID == 0 || req.SessionID == 0 {
+		return nil, nil
+	}
+	session, err := s.conversation.Session(ctx, req.UserID, req.SessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, errcode.Wrap(errcode.CodeSessionNotFound, err)
+	}
+	if err != nil {
+		logrus.WithContext(ctx).Warnf("获取对话会话失败，本轮不保存对话记录: %v", err)
+		return nil, nil
+	}
+	return session, nil
+}
+
+// startSession 本轮确定要保存时，为没有带会话的请求新建会话
+// 带了会话但查询失败（session 为 nil）的不新建，避免把一段对话拆到两个会话里
+func (s *ChatService) startSession(ctx context.Context, req *VoiceChatRequest, session *model.ConversationSession) *model.ConversationSession {
+	if session != nil || req.UserID == 0 || req.SessionID != 0 {
+		return session
+	}
+	session, err := s.conversation.NewSession(ctx, req.UserID)
 	if err != nil {
-		logrus.Errorf("LLM error: %v", err)
-		return "", err
+		logrus.WithContext(ctx).Warnf("创建对话会话失败，本轮不保存对话记录: %v", err)
+		return nil
+	}
+	return session
+}
+
+func sessionID(session *model.ConversationSession) uint {
+	if session == nil {
+		return 0
 	}
+	return session.ID
+}
+
+// recognize 识别孩子的录音，内存中的音频直接送去识别，不落盘
+func (s *ChatService) recognize(ctx context.Context, req *VoiceChatRequest) (*asr.Result, error) {
+	if req.AudioData != nil {
+		return s.asrService.RecognizeReader(ctx, bytes.NewReader(req.AudioData), asr.PacingNone)
+	}
+	return s.asrService.Recognize(ctx, req.AudioPath)
+}
 
-	logrus.Infof("🤖 AI Reply: %s", replyText)
+// saveTurn 保存本轮对话记录
+func (s *ChatService) saveTurn(ctx context.Context, req *VoiceChatRequest, session *model.ConversationSession, result *VoiceChatResult, replyAudio []byte, ttsElapsed, total time.Duration) {
+	if session == nil {
+		return
+	}
+	turn := &model.ConversationTurn{
+		SessionID: session.ID,
+		UserID:    req.UserID,
+		UserText:  result.UserText,
+		ReplyText: result.ReplyText,
+		Model:     result.Model,
+		Silent:    result.Silent,
+		Blocked:   result.Blocked,
+		ASRMs:     result.timings.asr.Milliseconds(),
+		LLMMs:     result.timings.llm.Milliseconds(),
+		TTSMs:     ttsElapsed.Milliseconds(),
+		TotalMs:   total.Milliseconds(),
+	}
+	s.conversation.SaveTurn(ctx, turn, TurnAudio{
+		UserAudioPath: req.AudioPath,
+		UserAudio:     req.AudioData,
+		ReplyAudio:    replyAudio,
+		ReplyFormat:   result.AudioFormat,
+	})
+}
 
-	// 3. 后续步骤：TTS (语音合成) 暂不在此处展示，通常在下一阶段实现
-	return replyText, nil
+// removeUpload 删除上传的临时文件，删除失败的由 janitor 按保留时间清理
+func (s *ChatService) removeUpload(ctx context.Context, path string) {
+	if path == "" {
+		return
+	}
+	size, err := janitor.RemoveFile(path)
+	if err != nil {
+		logrus.WithContext(ctx).Warnf("删除临时音频失败 %s: %v", path, err)
+		return
+	}
+	s.svcctx.Janitor.Record(janitor.ClassTempUpload, 1, size)
+}
+
+// turnOutcome 一轮对话的结果，用于端到端耗时指标
+func turnOutcome(result *VoiceChatResult, err error) string {
+	switch {
+	case err != nil:
+		return metrics.OutcomeError
+	case result.Blocked != "":
+		return metrics.OutcomeBlocked
+	case result.Silent:
+		return metrics.OutcomeSilent
+	default:
+		return metrics.OutcomeOK
+	}
+}
+
+// billedSeconds 识别的计费时长，服务端没有返回 usage 时按识别到的音频时长向上取整
+func billedSeconds(recognized *asr.Result) int {
+	if recognized.BilledSeconds > 0 {
+		return recognized.BilledSeconds
+	}
+	return (recognized.Duration + 999) / 1000
+}
+
+// synthesizeReply 合成回复语音
+// 合成失败不影响本轮对话，降级为只返回文本
+func (s *ChatService) synthesizeReply(ctx context.Context, req *VoiceChatRequest, result *VoiceChatResult) {
+	text, opts := s.replyOptions(req, result.ReplyText)
+	ttsStart := time.Now()
+	synthesis, err := s.ttsService.Synthesize(ctx, text, opts)
+	if err != nil {
+		metrics.ObserveTTS(metrics.ModeSync, time.Since(ttsStart), 0, err)
+		logrus.WithContext(ctx).Warnf("TTS error, 降级为纯文本回复: %v", err)
+		return
+	}
+	metrics.ObserveTTS(metrics.ModeSync, time.Since(ttsStart), len(synthesis.Audio), nil)
+	s.usage.RecordTTS(ctx, req.UserID, s.svcctx.Config.Aliyun.TTS.Model, synthesis.Characters)
+	result.ReplyAudio = synthesis.Audio
+	result.AudioFormat = opts.Format
+	result.SetWords(req.Subtitle, synthesis.Words)
+}
+
+// SetWords 填充词级时间戳并按请求的格式生成字幕
+func (r *VoiceChatResult) SetWords(format string, words []tts.WordTiming) {
+	r.Words = words
+	if format == "" || len(words) == 0 {
+		return
+	}
+	subtitles, err := tts.Subtitles(format, words)
+	if err != nil {
+		logrus.Warnf("生成字幕失败: %v", err)
+		return
+	}
+	r.Subtitles = subtitles
+}
+
+// replyOptions 确定回复语音的合成参数
+// 回复里出现了本轮教学词汇时切换到 SSML 模式，把这些词放慢、重读
+func (s *ChatService) replyOptions(req *VoiceChatRequest, replyText string) (string, tts.SynthesisOptions) {
+	opts := tts.ResolveOptions(&s.svcctx.Config.Aliyun.TTS, req.Voice)
+	opts.WordTimestamps = req.Subtitle != ""
+	if ssml, ok := tts.KidFriendlySSML(replyText, req.FocusWords); ok {
+		opts.SSML = true
+		return ssml, opts
+	}
+	return replyText, opts
 }
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"oktalk/internal/model"
+	"oktalk/internal/pkg/blob"
+	"oktalk/internal/servicecontext"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSessionNotFound 会话不存在或不属于当前孩子
+var ErrSessionNotFound = errors.New("会话不存在")
+
+// ConversationService 保存对话记录（文本、录音、回复语音、耗时），供家长回放
+// 录音和回复语音保存在 BlobStore 中，数据库里只记录 key
+type ConversationService struct {
+	svcctx *servicecontext.ServiceContext
+}
+
+func NewConversationService(svcctx *servicecontext.ServiceContext) *ConversationService {
+	return &ConversationService{svcctx: svcctx}
+}
+
+// NewSession 新建一个会话
+func (s *ConversationService) NewSession(ctx context.Context, userID uint) (*model.ConversationSession, error) {
+	session := &model.ConversationSession{UserID: userID, LastActiveAt: time.Now()}
+	return session, s.svcctx.DB.WithContext(ctx).Create(session).Error
+}
+
+// Session 查询本轮对话所属的已有会话，必须是该孩子自己的会话
+func (s *ConversationService) Session(ctx context.Context, userID, sessionID uint) (*model.ConversationSession, error) {
+	var session model.ConversationSession
+	err := s.svcctx.DB.WithContext(ctx).Take(&session, "id = ? AND user_id = ?", sessionID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	return &session, err
+}
+
+// TurnAudio 一轮对话需要保存的音频
+type TurnAudio struct {
+	UserAudioPath string // 孩子录音的本地路径（上传的临时文件）
+	UserAudio     []byte // 内存中的孩子录音（已校验为 WAV），不为空时不使用 UserAudioPath
+	ReplyAudio    []byte
+	ReplyFormat   string
+}
+
+// SaveTurn 保存一轮对话：分配序号、把音频写入存储、写入记录
+// 保存失败只打日志，不影响本轮对话
+func (s *ConversationService) SaveTurn(ctx context.Context, turn *model.ConversationTurn, audio TurnAudio) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.nextSeq(ctx, turn); err != nil {
+		logrus.WithContext(ctx).Errorf("保存对话记录失败: %v", err)
+		return
+	}
+
+	prefix := fmt.Sprintf("%d/%d/%d", turn.UserID, turn.SessionID, turn.Seq)
+	switch {
+	case len(audio.UserAudio) > 0:
+		key := blob.RecordingPrefix + prefix + "_user.wav"
+		err := s.svcctx.Blob.Put(ctx, key, bytes.NewReader(audio.UserAudio), int64(len(audio.UserAudio)), blob.AudioContentType("wav"))
+		if err != nil {
+			logrus.WithContext(ctx).Warnf("保存孩子录音失败: %v", err)
+		} else {
+			turn.UserAudio = key
+		}
+	case audio.UserAudioPath != "":
+		ext := filepath.Ext(audio.UserAudioPath)
+		key := blob.RecordingPrefix + prefix + "_user" + ext
+		if err := s.putFile(ctx, key, audio.UserAudioPath, blob.AudioContentType(ext)); err != nil {
+			logrus.WithContext(ctx).Warnf("保存孩子录音失败: %v", err)
+		} else {
+			turn.UserAudio = key
+		}
+	}
+	if len(audio.ReplyAudio) > 0 {
+		key := blob.ReplyPrefix + prefix + "_reply." + audio.ReplyFormat
+		err := s.svcctx.Blob.Put(ctx, key, bytes.NewReader(audio.ReplyAudio), int64(len(audio.ReplyAudio)), blob.AudioContentType(audio.ReplyFormat))
+		if err != nil {
+			logrus.WithContext(ctx).Warnf("保存回复语音失败: %v", err)
+		} else {
+			turn.ReplyAudio = key
+		}
+	}
+
+	if err := s.svcctx.DB.WithContext(ctx).Create(turn).Error; err != nil {
+		logrus.WithContext(ctx).Errorf("保存对话记录失败: %v", err)
+	}
+}
+
+// nextSeq 给本轮分配会话内的序号，同时更新会话的轮数和最后活跃时间
+func (s *ConversationService) nextSeq(ctx context.Context, turn *model.ConversationTurn) error {
+	return s.svcctx.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var session model.ConversationSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&session, turn.SessionID).Error; err != nil {
+			return err
+		}
+		turn.Seq = session.TurnCount + 1
+		return tx.Model(&session).Updates(map[string]any{
+			"turn_count":     turn.Seq,
+			"last_active_at": time.Now(),
+		}).Error
+	})
+}
+
+func (s *ConversationService) putFile(ctx context.Context, key, path, contentType string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return s.svcctx.Blob.Put(ctx, key, f, info.Size(), contentType)
+}
+
+// AudioURL 音频的下载链接，有效期为 storage.signed_url_ttl_seconds
+func (s *ConversationService) AudioURL(ctx context.Context, key string) (string, error) {
+	ttl := time.Duration(s.svcctx.Config.Storage.SignedURLTTLSeconds) * time.Second
+	return s.svcctx.Blob.SignedURL(ctx, key, ttl)
+}
+
+// ListSessions 按时间倒序分页列出访问者可以查看的会话，userID 不为 0 时只列出这个孩子的
+func (s *ConversationService) ListSessions(ctx context.Context, g *Guardian, userID uint, page, size int) ([]model.ConversationSession, int64, error) {
+	db := s.svcctx.DB.WithContext(ctx).Model(&model.ConversationSession{}).Scopes(g.OwnChildren).Where("turn_count > 0")
+	if userID != 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var sessions []model.ConversationSession
+	err := db.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&sessions).Error
+	return sessions, total, err
+}
+
+// GetSession 查询会话和其中的每一轮对话，用于回放
+// 会话不属于访问者绑定的孩子时与不存在一样返回 ErrSessionNotFound
+func (s *ConversationService) GetSession(ctx context.Context, g *Guardian, sessionID uint) (*model.ConversationSession, []model.ConversationTurn, error) {
+	db := s.svcctx.DB.WithContext(ctx)
+	var session model.ConversationSession
+	err := db.Scopes(g.OwnChildren).Take(&session, "id = ?", sessionID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var turns []model.ConversationTurn
+	err = db.Where("session_id = ?", session.ID).Order("seq").Find(&turns).Error
+	return &session, turns, err
+}
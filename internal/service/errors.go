@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"oktalk/internal/pkg/asr"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/resilience"
+	"oktalk/internal/pkg/tts"
+)
+
+// asrError 把 asr 包的错误映射为业务码
+func asrError(err error) *errcode.AppError {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errcode.Wrap(errcode.CodeRequestCanceled, err)
+	case errors.Is(err, asr.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return errcode.Wrap(errcode.CodeASRTimeout, err)
+	case errors.Is(err, resilience.ErrCircuitOpen):
+		return errcode.Wrap(errcode.CodeASRUnavailable, err)
+	case errors.Is(err, asr.ErrAuthFailed):
+		return errcode.Wrap(errcode.CodeASRAuthFailed, err)
+	case errors.Is(err, asr.ErrQuotaExceeded):
+		return errcode.Wrap(errcode.CodeASRQuotaExceeded, err)
+	case errors.Is(err, asr.ErrBadAudio):
+		return errcode.Wrap(errcode.CodeASRBadAudio, err)
+	default:
+		return errcode.Wrap(errcode.CodeASRTaskFailed, err)
+	}
+}
+
+// llmError 把 LLM 调用错误映射为业务码
+func llmError(err error) *errcode.AppError {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errcode.Wrap(errcode.CodeRequestCanceled, err)
+	case errors.Is(err, resilience.ErrCircuitOpen):
+		return errcode.Wrap(errcode.CodeLLMUnavailable, err)
+	}
+	return errcode.Wrap(errcode.CodeLLMFailed, err)
+}
+
+// TTSError 把合成中途的错误映射为业务码，用于流式回复的 error 事件
+func TTSError(err error) *errcode.AppError {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errcode.Wrap(errcode.CodeRequestCanceled, err)
+	case errors.Is(err, tts.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return errcode.Wrap(errcode.CodeTTSTimeout, err)
+	}
+	return errcode.Wrap(errcode.CodeTTSInterrupted, err)
+}
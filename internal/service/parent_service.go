@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"oktalk/internal/model"
+	"oktalk/internal/servicecontext"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidParentToken 家长令牌为空或没有登记
+	ErrInvalidParentToken = errors.New("家长令牌无效")
+	// ErrChildNotFound 孩子没有绑定到当前家长
+	ErrChildNotFound = errors.New("孩子不存在或未绑定到当前家长")
+	// ErrParentNotFound 家长账号不存在
+	ErrParentNotFound = errors.New("家长不存在")
+)
+
+// Guardian 通过家长鉴权的访问者：管理员可以访问所有孩子，家长只能访问自己绑定的孩子
+// 家长身份来自 X-Parent-Token，与孩子自报的 X-User-ID 无关
+type Guardian struct {
+	ParentID uint
+	Admin    bool
+}
+
+// ParentService 家长账号和家长与孩子的绑定关系
+type ParentService struct {
+	svcctx *servicecontext.ServiceContext
+}
+
+func NewParentService(svcctx *servicecontext.ServiceContext) *ParentService {
+	return &ParentService{svcctx: svcctx}
+}
+
+// Authenticate 按令牌查找家长
+func (s *ParentService) Authenticate(ctx context.Context, token string) (*model.Parent, error) {
+	if token == "" {
+		return nil, ErrInvalidParentToken
+	}
+	var parent model.Parent
+	err := s.svcctx.DB.WithContext(ctx).Take(&parent, "token_hash = ?", hashToken(token)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidParentToken
+	}
+	return &parent, err
+}
+
+// Create 新建家长账号并绑定孩子，返回的令牌只有这一次机会拿到
+func (s *ParentService) Create(ctx context.Context, name string, children []uint) (*model.Parent, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	token := hex.EncodeToString(raw)
+	parent := &model.Parent{Name: name, TokenHash: hashToken(token)}
+	err := s.svcctx.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(parent).Error; err != nil {
+			return err
+		}
+		return bindChildren(tx, parent.ID, children)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return parent, token, nil
+}
+
+// AddChildren 给已有的家长绑定孩子，已经绑定的忽略
+func (s *ParentService) AddChildren(ctx context.Context, parentID uint, children []uint) error {
+	db := s.svcctx.DB.WithContext(ctx)
+	err := db.Take(&model.Parent{}, parentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrParentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return bindChildren(db, parentID, children)
+}
+
+// CheckChild 确认访问者可以访问这个孩子，不能访问时返回 ErrChildNotFound
+func (s *ParentService) CheckChild(ctx context.Context, g *Guardian, userID uint) error {
+	if userID == 0 {
+		return ErrChildNotFound
+	}
+	if g.Admin {
+		return nil
+	}
+	var n int64
+	err := s.svcctx.DB.WithContext(ctx).Model(&model.ParentChild{}).
+		Where("parent_id = ? AND user_id = ?", g.ParentID, userID).Count(&n).Error
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrChildNotFound
+	}
+	return nil
+}
+
+// OwnChildren gorm scope：把 user_id 限定为访问者绑定的孩子，管理员不限制
+func (g *Guardian) OwnChildren(db *gorm.DB) *gorm.DB {
+	if g.Admin {
+		return db
+	}
+	return db.Where("user_id IN (?)", db.Session(&gorm.Session{NewDB: true}).
+		Model(&model.ParentChild{}).Select("user_id").Where("parent_id = ?", g.ParentID))
+}
+
+func bindChildren(db *gorm.DB, parentID uint, children []uint) error {
+	if len(children) == 0 {
+		return nil
+	}
+	rows := make([]model.ParentChild, 0, len(children))
+	for _, userID := range children {
+		rows = append(rows, model.ParentChild{ParentID: parentID, UserID: userID})
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"oktalk/internal/model"
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/servicecontext"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 家长限制导致本轮对话被拒绝的原因
+const (
+	BlockedDailyLimit = "daily_limit" // 今日使用时长已达上限
+	BlockedQuietHours = "quiet_hours" // 不在允许使用的时间段
+	BlockedNoUser     = "no_user"     // 没有带 X-User-ID，parental.anonymous 为 deny
+)
+
+// 对孩子说的话，会合成语音播放，所以用 AI 老师的口吻
+const (
+	dailyLimitMessage = "Great job today! You have practiced a lot. Let's take a rest and talk again tomorrow!"
+	quietHoursMessage = "It's resting time now. Let's practice together again later. See you soon!"
+	noUserMessage     = "Hi there! Please ask your mom or dad to sign you in first, then we can practice together!"
+	breakReminder     = "You have been practicing for %d minutes. Let's rest your eyes and drink some water!"
+)
+
+// ParentalService 家长控制：每日时长上限、允许使用的时间段、休息提醒
+// 使用时长累计在 UserLearningRecord.Duration 中（按天一条记录）。
+// 孩子按 X-User-ID 识别，这个 ID 由客户端自报，换一个 ID 就是另一个孩子的限制，所以限制只和 ID 一样可靠；
+// 没有带 ID 的请求按 parental.anonymous 处理，不能靠省略请求头绕过
+type ParentalService struct {
+	svcctx *servicecontext.ServiceContext
+	conf   config.ParentalConfig
+	loc    *time.Location // parental.timezone
+}
+
+func NewParentalService(svcctx *servicecontext.ServiceContext) *ParentalService {
+	conf := svcctx.Config.Parental
+	loc, err := time.LoadLocation(conf.Timezone)
+	if err != nil {
+		// 启动时已经校验过，这里只是兜底
+		logrus.Warnf("parental.timezone 无效，使用服务器本地时区: %v", err)
+		loc = time.Local
+	}
+	return &ParentalService{svcctx: svcctx, conf: conf, loc: loc}
+}
+
+// ParentalVerdict 一轮对话开始前的检查结果
+type ParentalVerdict struct {
+	Blocked string // 为空表示允许使用
+	Message string // 被拒绝时对孩子说的话
+
+	control     *model.ParentalControl
+	loc         *time.Location // 判断允许时段和“今天”的时区
+	usedSeconds int            // 今天已经使用的秒数
+}
+
+// Get 查询孩子的家长设置，没有设置过时返回 parental.default
+func (s *ParentalService) Get(ctx context.Context, userID uint) (*model.ParentalControl, error) {
+	control := &model.ParentalControl{UserID: userID}
+	err := s.svcctx.DB.WithContext(ctx).Take(control, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.defaults(userID), nil
+	}
+	return control, err
+}
+
+// defaults 配置中的默认限制
+func (s *ParentalService) defaults(userID uint) *model.ParentalControl {
+	d := s.conf.Default
+	return &model.ParentalControl{
+		UserID:            userID,
+		MaxDailyMinutes:   d.MaxDailyMinutes,
+		AllowedFrom:       d.AllowedFrom,
+		AllowedTo:         d.AllowedTo,
+		BreakEveryMinutes: d.BreakEveryMinutes,
+	}
+}
+
+// location 孩子的时区，没有设置时使用 parental.timezone
+func (s *ParentalService) location(control *model.ParentalControl) *time.Location {
+	if control.Timezone == "" {
+		return s.loc
+	}
+	loc, err := time.LoadLocation(control.Timezone)
+	if err != nil {
+		return s.loc
+	}
+	return loc
+}
+
+// Save 保存孩子的家长设置并回填保存后的记录，参数不合法时返回 CodeParamError，Detail 中是出错的字段
+func (s *ParentalService) Save(ctx context.Context, control *model.ParentalControl) error {
+	if control.MaxDailyMinutes < 0 {
+		return errcode.New(errcode.CodeParamError).WithDetail("max_daily_minutes >= 0")
+	}
+	if control.BreakEveryMinutes < 0 {
+		return errcode.New(errcode.CodeParamError).WithDetail("break_every_minutes >= 0")
+	}
+	if (control.AllowedFrom == "") != (control.AllowedTo == "") {
+		return errcode.New(errcode.CodeParamError).WithDetail("allowed_from + allowed_to")
+	}
+	if _, err := parseClock(control.AllowedFrom); control.AllowedFrom != "" && err != nil {
+		return errcode.Wrap(errcode.CodeParamError, err).WithDetail("allowed_from (HH:MM)")
+	}
+	if _, err := parseClock(control.AllowedTo); control.AllowedTo != "" && err != nil {
+		return errcode.Wrap(errcode.CodeParamError, err).WithDetail("allowed_to (HH:MM)")
+	}
+	if _, err := time.LoadLocation(control.Timezone); err != nil {
+		return errcode.Wrap(errcode.CodeParamError, err).WithDetail("timezone (IANA, e.g. Asia/Shanghai)")
+	}
+	// 已有设置时只更新可修改的列，保留原来的 created_at
+	db := s.svcctx.DB.WithContext(ctx)
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_daily_minutes", "allowed_from", "allowed_to", "break_every_minutes", "timezone", "updated_at"}),
+	}).Create(control).Error
+	if err != nil {
+		return err
+	}
+	return db.Take(control, "user_id = ?", control.UserID).Error
+}
+
+// Admit 检查孩子现在能否开始一轮对话
+// 允许时段按孩子的时区判断。没有带用户 ID 时按 parental.anonymous 处理，匿名请求无法统计时长，只检查允许时段。
+// 查询失败时放行，家长控制故障不能让孩子完全无法使用
+func (s *ParentalService) Admit(ctx context.Context, userID uint, now time.Time) *ParentalVerdict {
+	verdict := &ParentalVerdict{}
+	var control *model.ParentalControl
+	if userID == 0 {
+		switch s.conf.Anonymous {
+		case config.AnonymousAllow:
+			return verdict
+		case config.AnonymousDefault:
+			control = s.defaults(0)
+		default:
+			verdict.Blocked, verdict.Message = BlockedNoUser, noUserMessage
+			return verdict
+		}
+	} else {
+		var err error
+		if control, err = s.Get(ctx, userID); err != nil {
+			logrus.WithContext(ctx).Warnf("查询家长设置失败，放行请求: %v", err)
+			return verdict
+		}
+	}
+	verdict.control = control
+	verdict.loc = s.location(control)
+	now = now.In(verdict.loc)
+
+	if !inAllowedWindow(now, control.AllowedFrom, control.AllowedTo) {
+		verdict.Blocked, verdict.Message = BlockedQuietHours, quietHoursMessage
+		return verdict
+	}
+	if userID == 0 || (control.MaxDailyMinutes == 0 && control.BreakEveryMinutes == 0) {
+		return verdict
+	}
+	var err error
+	verdict.usedSeconds, err = s.usedSeconds(ctx, userID, now)
+	if err != nil {
+		logrus.WithContext(ctx).Warnf("查询今日学习时长失败，放行请求: %v", err)
+		return verdict
+	}
+	if control.MaxDailyMinutes > 0 && verdict.usedSeconds >= control.MaxDailyMinutes*60 {
+		verdict.Blocked, verdict.Message = BlockedDailyLimit, dailyLimitMessage
+	}
+	return verdict
+}
+
+// Track 累计本轮对话的使用时长，跨过休息提醒的整点时返回提醒语
+func (s *ParentalService) Track(ctx context.Context, userID uint, verdict *ParentalVerdict, seconds int, now time.Time) string {
+	if userID == 0 || seconds <= 0 {
+		return ""
+	}
+	if verdict.loc != nil {
+		now = now.In(verdict.loc)
+	}
+	if err := s.addDuration(context.WithoutCancel(ctx), userID, seconds, now); err != nil {
+		logrus.WithContext(ctx).Warnf("累计学习时长失败: %v", err)
+	}
+
+	if verdict.control == nil || verdict.control.BreakEveryMinutes <= 0 {
+		return ""
+	}
+	every := verdict.control.BreakEveryMinutes * 60
+	before, after := verdict.usedSeconds, verdict.usedSeconds+seconds
+	if before/every == after/every {
+		return ""
+	}
+	return fmt.Sprintf(breakReminder, after/every*verdict.control.BreakEveryMinutes)
+}
+
+// usedSeconds 今天已经使用的秒数
+// 并发的第一轮对话可能各自创建当天的记录，因此按天求和而不是只取一条
+func (s *ParentalService) usedSeconds(ctx context.Context, userID uint, now time.Time) (int, error) {
+	var total int
+	err := s.svcctx.DB.WithContext(ctx).
+		Model(&model.UserLearningRecord{}).
+		Select("COALESCE(SUM(duration), 0)").
+		Where("user_id = ? AND date = ?", userID, dateOf(now)).
+		Scan(&total).Error
+	return total, err
+}
+
+// addDuration 把使用时长累加到当天的学习记录上，没有记录时新建
+func (s *ParentalService) addDuration(ctx context.Context, userID uint, seconds int, now time.Time) error {
+	db := s.svcctx.DB.WithContext(ctx)
+	record := model.UserLearningRecord{UserID: userID, Date: dateOf(now)}
+	if err := db.Where("user_id = ? AND date = ?", record.UserID, record.Date).FirstOrCreate(&record).Error; err != nil {
+		return err
+	}
+	return db.Model(&model.UserLearningRecord{}).
+		Where("id = ?", record.ID).
+		Update("duration", gorm.Expr("duration + ?", seconds)).Error
+}
+
+// dateOf t 所在时区的日期；date 列只存年月日，统一用本地时区的零点写入，避免驱动换算时区后日期错位
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// parseClock 解析 HH:MM，返回从零点开始的分钟数
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inAllowedWindow 判断 now 是否在 [from, to) 内，to 早于 from 表示跨零点（如 18:00 - 08:00）
+func inAllowedWindow(now time.Time, from, to string) bool {
+	if from == "" || to == "" {
+		return true
+	}
+	start, err1 := parseClock(from)
+	end, err2 := parseClock(to)
+	if err1 != nil || err2 != nil || start == end {
+		return true
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"oktalk/internal/pkg/config"
+)
+
+// 匿名请求不访问数据库，按 parental.anonymous 和 parental.default 处理
+func TestAdmitAnonymous(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("没有时区数据: %v", err)
+	}
+	window := config.ParentalDefaults{AllowedFrom: "07:00", AllowedTo: "21:00"}
+	// 北京时间 22:00，UTC 14:00
+	night := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	// 北京时间 10:00，UTC 02:00
+	morning := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		anonymous string
+		now       time.Time
+		want      string
+	}{
+		{"未配置时拒绝", "", morning, BlockedNoUser},
+		{"deny", config.AnonymousDeny, morning, BlockedNoUser},
+		{"allow", config.AnonymousAllow, night, ""},
+		{"default 按北京时间在允许时段内", config.AnonymousDefault, morning, ""},
+		{"default 按北京时间不在允许时段内", config.AnonymousDefault, night, BlockedQuietHours},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &ParentalService{conf: config.ParentalConfig{Anonymous: tc.anonymous, Default: window}, loc: shanghai}
+			if got := s.Admit(context.Background(), 0, tc.now).Blocked; got != tc.want {
+				t.Errorf("Blocked = %q，期望 %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"oktalk/internal/model"
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/ratelimit"
+	"oktalk/internal/servicecontext"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// usageCounter 每日用量计数，由 ratelimit.Quota 实现
+type usageCounter interface {
+	Usage(ctx context.Context, subject string) (ratelimit.DailyUsage, error)
+	Add(ctx context.Context, subject string, turns, voiceSeconds int64) error
+}
+
+// QuotaService 按套餐检查和累计每日额度
+type QuotaService struct {
+	svcctx *servicecontext.ServiceContext
+	conf   config.QuotaConfig
+	quota  usageCounter
+}
+
+func NewQuotaService(svcctx *servicecontext.ServiceContext) *QuotaService {
+	return &QuotaService{
+		svcctx: svcctx,
+		conf:   svcctx.Config.Quota,
+		quota:  ratelimit.NewQuota(svcctx.Redis),
+	}
+}
+
+// quotaRule 一个统计主体和它的每日额度
+type quotaRule struct {
+	subject string
+	limits  config.PlanQuota
+}
+
+// rules 本次请求要检查和累计的额度：
+// 带了用户 ID 时按用户的套餐计数；匿名请求按 IP 计数，使用默认套餐。
+// 另外同一个 IP 下所有请求合计一份 quota.ip 额度：X-User-ID 由客户端自报，换一个 ID 就能绕过按用户的额度，
+// 合计额度用来兜底，学校、家庭共用出口 IP，所以要远高于单个用户的额度
+func (s *QuotaService) rules(userID uint, clientIP string, limits config.PlanQuota) []quotaRule {
+	rules := []quotaRule{{subject: "ip:" + clientIP, limits: s.conf.IP}}
+	if userID != 0 {
+		return append(rules, quotaRule{subject: fmt.Sprintf("user:%d", userID), limits: limits})
+	}
+	return append(rules, quotaRule{subject: "anon:" + clientIP, limits: limits})
+}
+
+// Check 检查今日额度，用完时返回带 RetryAfter 的 *errcode.AppError
+// 查询套餐或读取计数失败时放行，额度故障不能影响孩子正常使用
+func (s *QuotaService) Check(ctx context.Context, userID uint, clientIP string) error {
+	if !s.conf.Enabled {
+		return nil
+	}
+	plan, limits := s.plan(ctx, userID)
+	return s.check(ctx, userID, clientIP, plan, limits)
+}
+
+func (s *QuotaService) check(ctx context.Context, userID uint, clientIP, plan string, limits config.PlanQuota) error {
+	for _, rule := range s.rules(userID, clientIP, limits) {
+		if rule.limits.DailyTurns <= 0 && rule.limits.DailyVoiceMinutes <= 0 {
+			continue
+		}
+		usage, err := s.quota.Usage(ctx, rule.subject)
+		if err != nil {
+			logrus.WithContext(ctx).Warnf("读取每日额度失败，放行请求: %v", err)
+			continue
+		}
+		exceeded := (rule.limits.DailyTurns > 0 && usage.Turns >= int64(rule.limits.DailyTurns)) ||
+			(rule.limits.DailyVoiceMinutes > 0 && usage.VoiceSeconds >= int64(rule.limits.DailyVoiceMinutes)*60)
+		if exceeded {
+			logrus.WithContext(ctx).Infof("%s（用户 %d，套餐 %s）今日额度已用完: %+v", rule.subject, userID, plan, usage)
+			return errcode.New(errcode.CodeQuotaExceeded).WithRetryAfter(ratelimit.UntilReset(time.Now()))
+		}
+	}
+	return nil
+}
+
+// Consume 累计一轮对话和孩子说话的时长
+func (s *QuotaService) Consume(ctx context.Context, userID uint, clientIP string, voiceSeconds int) {
+	if !s.conf.Enabled {
+		return
+	}
+	for _, rule := range s.rules(userID, clientIP, config.PlanQuota{}) {
+		if err := s.quota.Add(context.WithoutCancel(ctx), rule.subject, 1, int64(voiceSeconds)); err != nil {
+			logrus.WithContext(ctx).Warnf("累计每日额度失败: %v", err)
+		}
+	}
+}
+
+// plan 查询用户的套餐，没有开通或查询失败时使用默认套餐
+func (s *QuotaService) plan(ctx context.Context, userID uint) (string, config.PlanQuota) {
+	name := s.conf.DefaultPlan
+	if userID != 0 {
+		var up model.UserPlan
+		err := s.svcctx.DB.WithContext(ctx).Take(&up, "user_id = ?", userID).Error
+		switch {
+		case err == nil && up.Plan != "":
+			name = up.Plan
+		case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+			logrus.WithContext(ctx).Warnf("查询用户套餐失败，使用默认套餐: %v", err)
+		}
+	}
+	limits, ok := s.conf.Plans[name]
+	if !ok {
+		logrus.WithContext(ctx).Warnf("套餐 %s 没有配置额度，使用默认套餐", name)
+		name = s.conf.DefaultPlan
+		limits = s.conf.Plans[name]
+	}
+	return name, limits
+}
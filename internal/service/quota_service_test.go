@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/errcode"
+	"oktalk/internal/pkg/ratelimit"
+)
+
+// memCounter 内存中的每日用量计数
+type memCounter struct {
+	mu    sync.Mutex
+	usage map[string]ratelimit.DailyUsage
+}
+
+func (m *memCounter) Usage(ctx context.Context, subject string) (ratelimit.DailyUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage[subject], nil
+}
+
+func (m *memCounter) Add(ctx context.Context, subject string, turns, voiceSeconds int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := m.usage[subject]
+	u.Turns += turns
+	u.VoiceSeconds += voiceSeconds
+	m.usage[subject] = u
+	return nil
+}
+
+// 同一个 IP 下，一个孩子用完自己的额度不影响另一个孩子，所有孩子合计用完 IP 额度时才一起拒绝
+func TestQuotaUsersBehindSameIP(t *testing.T) {
+	const ip = "10.0.0.1"
+	free := config.PlanQuota{DailyTurns: 2}
+	premium := config.PlanQuota{DailyTurns: 5}
+	s := &QuotaService{
+		conf:  config.QuotaConfig{Enabled: true, IP: config.PlanQuota{DailyTurns: 6}},
+		quota: &memCounter{usage: map[string]ratelimit.DailyUsage{}},
+	}
+	ctx := context.Background()
+	exceeded := func(err error) bool {
+		var appErr *errcode.AppError
+		return errors.As(err, &appErr) && appErr.Code == errcode.CodeQuotaExceeded
+	}
+
+	for i := 0; i < 2; i++ {
+		s.Consume(ctx, 1, ip, 10)
+	}
+	if err := s.check(ctx, 1, ip, "free", free); !exceeded(err) {
+		t.Fatalf("用户 1 用完免费额度后应被拒绝，实际 %v", err)
+	}
+	if err := s.check(ctx, 2, ip, "premium", premium); err != nil {
+		t.Fatalf("用户 2 不应受用户 1 的影响: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		s.Consume(ctx, 2, ip, 10)
+	}
+	if err := s.check(ctx, 3, ip, "premium", premium); !exceeded(err) {
+		t.Fatalf("IP 合计用完后新用户也应被拒绝，实际 %v", err)
+	}
+	if err := s.check(ctx, 3, "10.0.0.2", "premium", premium); err != nil {
+		t.Fatalf("其他 IP 不受影响: %v", err)
+	}
+}
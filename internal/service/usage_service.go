@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"oktalk/internal/model"
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/constants"
+	"oktalk/internal/pkg/llm"
+	"oktalk/internal/servicecontext"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UsageService 记录 AI 服务用量并统计成本
+type UsageService struct {
+	svcctx  *servicecontext.ServiceContext
+	pricing config.PricingConfig
+}
+
+func NewUsageService(svcctx *servicecontext.ServiceContext) *UsageService {
+	return &UsageService{
+		svcctx:  svcctx,
+		pricing: svcctx.Config.Pricing,
+	}
+}
+
+// RecordASR 记录一次语音识别的音频时长
+func (s *UsageService) RecordASR(ctx context.Context, userID uint, modelName string, seconds int) {
+	if seconds <= 0 {
+		return
+	}
+	price, _ := s.price(ctx, modelName)
+	s.record(ctx, &model.UsageRecord{
+		UserID:       userID,
+		Provider:     model.UsageProviderASR,
+		Model:        modelName,
+		AudioSeconds: seconds,
+		Cost:         float64(seconds) * price.PerAudioSecond,
+	})
+}
+
+// RecordLLM 记录一次对话生成的 token 数
+func (s *UsageService) RecordLLM(ctx context.Context, userID uint, reply *llm.Reply) {
+	if reply.PromptTokens == 0 && reply.CompletionTokens == 0 {
+		return
+	}
+	price, _ := s.price(ctx, reply.Model)
+	s.record(ctx, &model.UsageRecord{
+		UserID:           userID,
+		Provider:         model.UsageProviderLLM,
+		Model:            reply.Model,
+		PromptTokens:     reply.PromptTokens,
+		CompletionTokens: reply.CompletionTokens,
+		Cost: float64(reply.PromptTokens)/1000*price.InputPer1KTokens +
+			float64(reply.CompletionTokens)/1000*price.OutputPer1KTokens,
+	})
+}
+
+// RecordTTS 记录一次语音合成的计费字符数，命中缓存时字符数为 0，不记录
+func (s *UsageService) RecordTTS(ctx context.Context, userID uint, modelName string, characters int) {
+	if characters <= 0 {
+		return
+	}
+	price, _ := s.price(ctx, modelName)
+	s.record(ctx, &model.UsageRecord{
+		UserID:     userID,
+		Provider:   model.UsageProviderTTS,
+		Model:      modelName,
+		Characters: characters,
+		Cost:       float64(characters) / 10000 * price.Per10KCharacters,
+	})
+}
+
+// price 查找单价，未配置的模型成本按 0 计算，只记录用量
+func (s *UsageService) price(ctx context.Context, modelName string) (config.ModelPrice, bool) {
+	price, ok := s.pricing.Price(modelName)
+	if !ok {
+		logrus.WithContext(ctx).Warnf("模型 %s 没有配置单价，成本按 0 计算", modelName)
+	}
+	return price, ok
+}
+
+// record 写入用量记录，失败只打日志，不影响对话
+// 请求结束（如客户端断开）后仍然要记录已经产生的用量，因此不继承 ctx 的取消
+func (s *UsageService) record(ctx context.Context, rec *model.UsageRecord) {
+	if traceID, ok := ctx.Value(constants.TraceIDKey).(string); ok {
+		rec.TraceID = traceID
+	}
+	if err := s.svcctx.DB.WithContext(context.WithoutCancel(ctx)).Create(rec).Error; err != nil {
+		logrus.WithContext(ctx).Errorf("写入用量记录失败: %v", err)
+	}
+}
+
+// ErrUnsupportedGroupBy 不支持的分组方式
+var ErrUnsupportedGroupBy = errors.New("不支持的分组方式")
+
+// UsageQuery 用量统计的查询条件
+type UsageQuery struct {
+	GroupBy string    // day / user / provider / model
+	From    time.Time // 包含
+	To      time.Time // 不包含
+	UserID  uint      // 为 0 时统计所有用户
+}
+
+// UsageSummary 一个分组的用量汇总
+type UsageSummary struct {
+	Key              string  `json:"key"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	AudioSeconds     int64   `json:"audio_seconds"`
+	Characters       int64   `json:"characters"`
+	Cost             float64 `json:"cost"`
+}
+
+// usageGroupColumns 分组方式 -> 分组表达式
+var usageGroupColumns = map[string]string{
+	"day":      "DATE_FORMAT(created_at, '%Y-%m-%d')",
+	"user":     "user_id",
+	"provider": "provider",
+	"model":    "model",
+}
+
+// Summary 按天 / 用户 / 服务 / 模型汇总用量和成本
+func (s *UsageService) Summary(ctx context.Context, q UsageQuery) ([]UsageSummary, error) {
+	column, ok := usageGroupColumns[q.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedGroupBy, q.GroupBy)
+	}
+
+	db := s.svcctx.DB.WithContext(ctx).
+		Model(&model.UsageRecord{}).
+		Select(column+" AS `key`, COUNT(*) AS requests, "+
+			"SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, "+
+			"SUM(audio_seconds) AS audio_seconds, SUM(characters) AS characters, SUM(cost) AS cost").
+		Where("created_at >= ? AND created_at < ?", q.From, q.To)
+	if q.UserID != 0 {
+		db = db.Where("user_id = ?", q.UserID)
+	}
+
+	var items []UsageSummary
+	err := db.Group("`key`").Order("`key`").Scan(&items).Error
+	return items, err
+}
+
+// Currency 成本的货币单位
+func (s *UsageService) Currency() string {
+	return s.pricing.Currency
+}
@@ -0,0 +1,80 @@
+package servicecontext
+
+import (
+	"context"
+	"time"
+
+	"oktalk/internal/pkg/blob"
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/janitor"
+
+	"github.com/sirupsen/logrus"
+)
+
+// InitBlobStore 初始化音频存储并设置保留时间规则
+func InitBlobStore(conf *config.Config) blob.BlobStore {
+	store, err := blob.New(conf.Storage)
+	if err != nil {
+		logrus.Fatalf("❌ 音频存储初始化失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// 生命周期规则设置失败不影响读写，只是过期文件不会被自动删除
+	if err := store.ApplyLifecycle(ctx, lifecycleRules(conf.Retention)); err != nil {
+		logrus.Warnf("⚠️ 设置音频保留规则失败: %v", err)
+	}
+	logrus.Infof("✅ 音频存储(%s)初始化成功", conf.Storage.Backend)
+	return store
+}
+
+func lifecycleRules(conf config.RetentionConfig) []config.LifecycleRule {
+	return []config.LifecycleRule{
+		{Prefix: blob.RecordingPrefix, ExpireDays: conf.RecordingDays},
+		{Prefix: blob.ReplyPrefix, ExpireDays: conf.TTSOutputDays},
+	}
+}
+
+// InitJanitor 按保留策略注册各类文件并启动后台清理
+// S3 存储的过期对象由桶的生命周期规则删除，这里只清理本地文件
+func InitJanitor(conf *config.Config, store blob.BlobStore) *janitor.Janitor {
+	retention := conf.Retention
+	j := janitor.New(time.Duration(retention.SweepIntervalMinutes) * time.Minute)
+
+	if conf.Storage.TempDir != "" {
+		j.Add(janitor.Rule{
+			Class:  janitor.ClassTempUpload,
+			MaxAge: time.Duration(retention.TempUploadMinutes) * time.Minute,
+			Sweep:  janitor.SweepDir(conf.Storage.TempDir),
+		})
+	}
+	if local, ok := store.(*blob.LocalStore); ok {
+		j.Add(janitor.Rule{
+			Class:  janitor.ClassRecording,
+			MaxAge: time.Duration(retention.RecordingDays) * 24 * time.Hour,
+			Sweep:  sweepPrefix(local, blob.RecordingPrefix),
+		})
+		j.Add(janitor.Rule{
+			Class:  janitor.ClassTTSOutput,
+			MaxAge: time.Duration(retention.TTSOutputDays) * 24 * time.Hour,
+			Sweep:  sweepPrefix(local, blob.ReplyPrefix),
+		})
+	}
+	// TTS 缓存文件过了缓存有效期就不会再被读到
+	if cache := conf.Aliyun.TTS.Cache; cache.Enabled && cache.LocalDir != "" {
+		j.Add(janitor.Rule{
+			Class:  janitor.ClassTTSOutput,
+			MaxAge: time.Duration(cache.TTLSeconds) * time.Second,
+			Sweep:  janitor.SweepDir(cache.LocalDir),
+		})
+	}
+
+	j.Start()
+	return j
+}
+
+func sweepPrefix(store *blob.LocalStore, prefix string) janitor.SweepFunc {
+	return func(ctx context.Context, maxAge time.Duration) (int, int64) {
+		return store.SweepPrefix(ctx, prefix, maxAge)
+	}
+}
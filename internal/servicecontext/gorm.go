@@ -11,6 +11,7 @@ import (
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 // GormLogger 自定义 GORM 日志实现，对接我们的 logrus
@@ -59,6 +60,12 @@ func InitGORM(conf *config.Config) *gorm.DB {
 		logrus.Fatalf("❌ 数据库连接失败: %v", err)
 	}
 
+	// 每条 SQL 生成一个子 span，不记录参数值，避免孩子的对话内容进入 trace；
+	// 连接池指标由 Prometheus 负责
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutQueryVariables(), tracing.WithoutMetrics())); err != nil {
+		logrus.Warnf("⚠️ GORM trace 插件注册失败: %v", err)
+	}
+
 	// 配置连接池
 	sqlDB, _ := db.DB()
 	sqlDB.SetMaxIdleConns(conf.Database.MaxIdleConns)
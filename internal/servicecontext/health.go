@@ -0,0 +1,41 @@
+package servicecontext
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/health"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// InitHealth 注册 /readyz 检查的依赖：MySQL、Redis 为关键依赖，DashScope、讯飞按配置检查
+func InitHealth(conf *config.Config, db *gorm.DB, rdb *redis.Client) *health.Checker {
+	timeout := time.Duration(conf.Health.TimeoutSeconds) * time.Second
+	checker := health.NewChecker(time.Duration(conf.Health.CacheTTLSeconds)*time.Second, timeout)
+
+	checker.Register("mysql", true, func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	checker.Register("redis", true, func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	})
+
+	client := &http.Client{Timeout: timeout}
+	if conf.Health.CheckDashScope {
+		checker.Register("dashscope", false, health.HTTPCheck(client,
+			health.DashScopeRequest(conf.Aliyun.LLM.BaseURL, conf.Aliyun.DASHSCOPE_API_KEY)))
+	}
+	if conf.Health.CheckXfyun {
+		checker.Register("xfyun", false, health.HTTPCheck(client,
+			health.XfyunRequest(conf.Xfyun.WsURL, conf.Xfyun.ApiKey, conf.Xfyun.ApiSecret)))
+	}
+	return checker
+}
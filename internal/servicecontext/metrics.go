@@ -0,0 +1,19 @@
+package servicecontext
+
+import (
+	"oktalk/internal/pkg/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// registerMetrics 注册连接池和存储清理的指标，/metrics 抓取时读取
+func (s *ServiceContext) registerMetrics() {
+	if sqlDB, err := s.DB.DB(); err == nil {
+		metrics.RegisterDB(sqlDB)
+	} else {
+		logrus.Warnf("⚠️ 获取数据库连接池失败，不导出连接池指标: %v", err)
+	}
+	metrics.RegisterRedis(s.Redis)
+	metrics.RegisterDashScope(s.DashScope)
+	metrics.RegisterJanitor(s.Janitor)
+}
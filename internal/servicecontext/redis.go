@@ -6,6 +6,7 @@ import (
 	"oktalk/internal/pkg/config"
 	"time"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 )
@@ -27,6 +28,11 @@ func InitRedis(conf *config.Config) *redis.Client {
 		logrus.Fatalf("❌ Redis 连接失败: %v", err)
 	}
 
+	// 每条命令生成一个子 span
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		logrus.Warnf("⚠️ Redis trace 注册失败: %v", err)
+	}
+
 	logrus.Info("✅ Redis 初始化成功")
 	return rdb
 }
@@ -1,8 +1,16 @@
 package servicecontext
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
+
 	"oktalk/internal/model"
+	"oktalk/internal/pkg/blob"
 	"oktalk/internal/pkg/config"
+	"oktalk/internal/pkg/dashscope"
+	"oktalk/internal/pkg/health"
+	"oktalk/internal/pkg/janitor"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
@@ -10,9 +18,16 @@ import (
 )
 
 type ServiceContext struct {
-	Config *config.Config
-	DB     *gorm.DB
-	Redis  *redis.Client
+	Config    *config.Config
+	DB        *gorm.DB
+	Redis     *redis.Client
+	DashScope *dashscope.Pool // ASR / TTS 共用的 WebSocket 连接池
+	Blob      blob.BlobStore  // 孩子的录音和回复语音
+	Janitor   *janitor.Janitor
+	Health    *health.Checker // /readyz 的依赖检查
+
+	ready atomic.Bool    // 是否可以接收新流量，启动完成后为 true，退出时先置为 false
+	tasks sync.WaitGroup // 请求结束后仍在进行的后台任务
 }
 
 func NewServiceContext(conf *config.Config) *ServiceContext {
@@ -22,6 +37,13 @@ func NewServiceContext(conf *config.Config) *ServiceContext {
 	// 每次启动都会检查表结构，如果模型有变动会自动增加字段
 	err := db.AutoMigrate(
 		&model.UserLearningRecord{},
+		&model.UsageRecord{},
+		&model.UserPlan{},
+		&model.ParentalControl{},
+		&model.ConversationSession{},
+		&model.ConversationTurn{},
+		&model.Parent{},
+		&model.ParentChild{},
 		// 以后有新的 Model 往这里加即可
 	)
 	if err != nil {
@@ -32,9 +54,71 @@ func NewServiceContext(conf *config.Config) *ServiceContext {
 	// 2. 初始化 Redis
 	rdb := InitRedis(conf)
 
-	return &ServiceContext{
-		Config: conf,
-		DB:     db,
-		Redis:  rdb,
+	// 3. 初始化 DashScope 连接池
+	pool := dashscope.NewPool(conf.Aliyun.WsPool)
+
+	// 4. 初始化音频存储
+	store := InitBlobStore(conf)
+
+	// 5. 启动过期音频的后台清理
+	jan := InitJanitor(conf, store)
+
+	svcctx := &ServiceContext{
+		Config:    conf,
+		DB:        db,
+		Redis:     rdb,
+		DashScope: pool,
+		Blob:      store,
+		Janitor:   jan,
+		Health:    InitHealth(conf, db, rdb),
+	}
+	svcctx.registerMetrics()
+	return svcctx
+}
+
+// SetReady 设置是否可以接收新流量，退出时先标记为未就绪
+func (s *ServiceContext) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// Ready 是否可以接收新流量
+func (s *ServiceContext) Ready() bool {
+	return s.ready.Load()
+}
+
+// BeginTask 登记一个响应结束后仍在进行的后台任务（如流式回复结束后保存对话记录），
+// 任务结束时调用返回的函数。Close 会等这些任务结束后再释放资源
+func (s *ServiceContext) BeginTask() (done func()) {
+	s.tasks.Add(1)
+	return s.tasks.Done
+}
+
+// Close 在 HTTP 请求全部结束后按依赖顺序释放资源：
+// 等待后台任务（最长到 ctx 结束）-> 后台清理 -> DashScope 连接池 -> 音频存储 -> Redis -> 数据库
+func (s *ServiceContext) Close(ctx context.Context) {
+	finished := make(chan struct{})
+	go func() {
+		s.tasks.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		logrus.Warn("⚠️ 等待后台任务结束超时，直接释放资源")
+	}
+
+	s.Janitor.Close()
+	s.DashScope.Close()
+	if err := s.Blob.Close(); err != nil {
+		logrus.Warnf("⚠️ 关闭音频存储失败: %v", err)
+	}
+	if err := s.Redis.Close(); err != nil {
+		logrus.Warnf("⚠️ 关闭 Redis 失败: %v", err)
+	}
+	if sqlDB, err := s.DB.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			logrus.Warnf("⚠️ 关闭数据库失败: %v", err)
+		}
 	}
+	logrus.Info("✅ 资源已释放")
 }